@@ -4,23 +4,22 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"os"
-	"os/user"
-	"path/filepath"
-	"strings"
+	"net/url"
 	"time"
 
-	verbex "github.com/VerbalExpressions/GoVerbalExpressions"
 	"github.com/cloudfoundry/cli/plugin"
 	w "github.com/ibmjstart/cf-object-storage/writer"
 	"github.com/ibmjstart/swiftlygo/auth"
 )
 
-// timeout represents the amount of time before explicit new token requests.
-// OpenStack Object Storage deploys define an auth token timeout value that
-// this should correspond to.
+// timeout represents the amount of time before explicit new token requests
+// when the token's expiry time is unknown. OpenStack Object Storage deploys
+// define an auth token timeout value that this should correspond to.
 const timeout = "1h"
 
+// refreshMargin is how long before a token's expiry a new token is requested.
+const refreshMargin = "5m"
+
 // flagVal holds the flag values.
 type flagVal struct {
 	UrlFlag   bool
@@ -29,24 +28,37 @@ type flagVal struct {
 
 // credentials holds the info returned with a new cliConnection.
 type credentials struct {
-	AuthUrl    string `json:"Auth_URL"`
-	DomainID   string
-	DomainName string
-	Password   string
-	Project    string
-	ProjectID  string
-	Region     string
-	Role       string
-	UserID     string
-	Username   string
+	AuthUrl       string `json:"Auth_URL"`
+	DomainID      string
+	DomainName    string
+	Password      string
+	Project       string
+	ProjectID     string
+	ProjectDomain string `json:"project_domain"`
+	Region        string
+	Role          string
+	Tenant        string
+	TenantID      string `json:"tenant_id"`
+	UserDomain    string `json:"user_domain"`
+	UserID        string
+	Username      string
 }
 
 // authInfo holds the info used to restablish an existing connection.
 type authInfo struct {
-	AuthToken  string
-	Service    string
-	StorageUrl string
-	Timestamp  time.Time
+	AuthToken   string
+	AuthUrl     string
+	AuthVersion int
+	Region      string
+	Interface   string
+	ApiEndpoint string
+	Org         string
+	Space       string
+	Service     string
+	Key         string
+	StorageUrl  string
+	Timestamp   time.Time
+	Expires     time.Time
 }
 
 // authenticator holds the info required to authenticate with Object Storage.
@@ -57,15 +69,27 @@ type authenticator struct {
 
 	writer        *w.ConsoleWriter
 	flagVals      flagVal
+	options       Options
 	targetService string
+	serviceGuid   string
+	keyName       string
 	doSave        bool
 
-	logFile     *os.File
-	logFileSize int64
+	apiEndpoint string
+	org         string
+	space       string
+
+	cache authCache
+	store secretStore
 }
 
-// findService returns true if the target service is present in the current space.
+// findService ensures the target service is present in the target space and records its GUID.
 func (a *authenticator) findService() error {
+	// Services outside the current target are looked up through the Cloud Controller API
+	if a.options.Org != "" || a.options.Space != "" {
+		return a.findServiceInTarget()
+	}
+
 	// Get the services in the current space
 	services, err := a.cliConnection.GetServices()
 	if err != nil {
@@ -81,6 +105,7 @@ func (a *authenticator) findService() error {
 	for _, service := range services {
 		if service.Name == a.targetService {
 			found = true
+			a.serviceGuid = service.Guid
 		}
 	}
 
@@ -91,107 +116,57 @@ func (a *authenticator) findService() error {
 	return nil
 }
 
-// getCredentialsName returns the name of the target service's credentials.
-func (a *authenticator) getCredentialsName() (string, error) {
-	// Get the service keys for the target service
-	stdout, err := a.cliConnection.CliCommandWithoutTerminalOutput("service-keys", a.targetService)
+// findGuid returns the GUID of the resource with the given name in a Cloud Controller API collection.
+func (a *authenticator) findGuid(collectionPath, name string) (string, error) {
+	var page resourcesPage
+	err := a.curl(&page, collectionPath+"?q="+url.QueryEscape("name:"+name))
 	if err != nil {
-		return "", fmt.Errorf("Failed to find credentials for service '%s': %s", a.targetService, err)
+		return "", err
 	}
 
-	// Construct regex to extract credentials name
-	v := verbex.New().
-		Find("\nname\n").
-		BeginCapture().
-		AnythingBut("\n").
-		EndCapture().
-		Captures(strings.Join(stdout, "\n"))
-
-	// Get name of target service's credentials
-	var serviceCredentialsName string
-	if len(v) > 0 && len(v[0]) > 1 {
-		serviceCredentialsName = v[0][1]
-	} else {
-		return "", fmt.Errorf("Could not find credentials for target service")
+	if len(page.Resources) < 1 {
+		return "", fmt.Errorf("'%s' not found", name)
 	}
 
-	return serviceCredentialsName, nil
+	return page.Resources[0].Metadata.Guid, nil
 }
 
-// getJSONCredentials returns the target service's credentials.
-func (a *authenticator) getJSONCredentials(serviceCredentialsName string) (string, error) {
-	// Get the service key for the target service's credentials
-	stdout, err := a.cliConnection.CliCommandWithoutTerminalOutput("service-key", a.targetService, serviceCredentialsName)
+// findServiceInTarget ensures the target service is present in the org and
+// space given by the options and records its GUID, without changing the CLI's target.
+func (a *authenticator) findServiceInTarget() error {
+	err := a.getTarget()
 	if err != nil {
-		return "", fmt.Errorf("Failed to get credentials '%s' for service '%s': %s", serviceCredentialsName, a.targetService, err)
+		return fmt.Errorf("Failed to get target: %s", err)
 	}
 
-	// Construct regex to extract JSON
-	v := verbex.New().
-		AnythingBut("{").
-		BeginCapture().
-		Then("{").
-		Anything().
-		StartOfLine().
-		Then("}").
-		EndCapture().
-		Captures(strings.Join(stdout, ""))
-
-	// Get target service's credentials
-	var serviceCredentialsJSON string
-	if len(v) > 0 && len(v[0]) > 1 {
-		serviceCredentialsJSON = v[0][1]
-	} else {
-		return "", fmt.Errorf("Failed to fetch JSON credentials for service '%s'", a.targetService)
+	orgGuid, err := a.findGuid("/v2/organizations", a.org)
+	if err != nil {
+		return fmt.Errorf("Failed to find org: %s", err)
 	}
 
-	return serviceCredentialsJSON, nil
-}
+	spaceGuid, err := a.findGuid("/v2/organizations/"+orgGuid+"/spaces", a.space)
+	if err != nil {
+		return fmt.Errorf("Failed to find space in org %s: %s", a.org, err)
+	}
 
-// extractAuthFromJSON unmarshalls the JSON saved with a successful connection.
-func (a *authenticator) extractAuthFromJSON(authInfoJSON string) error {
-	var authInfo authInfo
-	err := json.Unmarshal([]byte(authInfoJSON), &authInfo)
+	serviceGuid, err := a.findGuid("/v2/spaces/"+spaceGuid+"/service_instances", a.targetService)
 	if err != nil {
-		return fmt.Errorf("Failed to unmarshall authentication info: %s", err)
+		return fmt.Errorf("Service '%s' not found in org %s / space %s", a.targetService, a.org, a.space)
 	}
 
-	a.authInfo = authInfo
+	a.serviceGuid = serviceGuid
 
 	return nil
 }
 
-// extractCredsFromJSON unmarshalls the JSON returned by a new cliConnection.
-func (a *authenticator) extractCredsFromJSON(serviceCredentialsJSON string) error {
+// extractCredsFromJSON unmarshalls the credentials of the target service's key.
+func (a *authenticator) extractCredsFromJSON(serviceCredentialsJSON []byte) error {
 	var creds credentials
-	err := json.Unmarshal([]byte(serviceCredentialsJSON), &creds)
+	err := json.Unmarshal(serviceCredentialsJSON, &creds)
 	if err != nil {
 		return fmt.Errorf("Failed to unmarshall JSON credentials: %s", err)
 	}
 
-	// unescape handles escaped unicode characters in JSON
-	// see: https://github.com/cloudfoundry/cli/issues/794
-	unescape := func(escaped string) string {
-		return strings.Replace(
-			strings.Replace(
-				strings.Replace(
-					escaped,
-					"\u003c", "<", -1),
-				"\u003e", ">", -1),
-			"\u0026", "&", -1)
-	}
-
-	creds.AuthUrl = unescape(creds.AuthUrl)
-	creds.DomainID = unescape(creds.DomainID)
-	creds.DomainName = unescape(creds.DomainName)
-	creds.Password = unescape(creds.Password)
-	creds.Project = unescape(creds.Project)
-	creds.ProjectID = unescape(creds.ProjectID)
-	creds.Region = unescape(creds.Region)
-	creds.Role = unescape(creds.Role)
-	creds.UserID = unescape(creds.UserID)
-	creds.Username = unescape(creds.Username)
-
 	a.creds = creds
 
 	return nil
@@ -214,22 +189,22 @@ func (a *authenticator) getNewCredentials() error {
 	}
 
 	// Get service keys for target service
-	a.writer.SetCurrentStage("Locating target service's credentials")
-	serviceCredentialsName, err := a.getCredentialsName()
+	a.writer.SetCurrentStage("Fetching target service's credentials")
+	serviceKeys, err := a.getServiceKeys()
 	if err != nil {
-		return fmt.Errorf("Failed to locate target service's credentials: %s", err)
+		return fmt.Errorf("Failed to fetch target service's credentials: %s", err)
 	}
 
-	// Fetch the JSON credentials
-	a.writer.SetCurrentStage("Fetching credentials")
-	serviceCredentialsJSON, err := a.getJSONCredentials(serviceCredentialsName)
+	// Choose the requested service key
+	serviceKey, err := a.chooseServiceKey(serviceKeys)
 	if err != nil {
-		return fmt.Errorf("Failed to fetch target service's credentials: %s", err)
+		return fmt.Errorf("Failed to locate target service's credentials: %s", err)
 	}
 
 	// Parse the JSON credentials
 	a.writer.SetCurrentStage("Parsing credentials")
-	err = a.extractCredsFromJSON(serviceCredentialsJSON)
+	a.keyName = serviceKey.Entity.Name
+	err = a.extractCredsFromJSON(serviceKey.Entity.Credentials)
 	if err != nil {
 		return fmt.Errorf("Failed to extract JSON credentials: %s", err)
 	}
@@ -240,69 +215,6 @@ func (a *authenticator) getNewCredentials() error {
 	return nil
 }
 
-// getSavedCredentials loads the locally saved credentails.
-func (a *authenticator) getSavedCredentials() error {
-	a.writer.SetCurrentStage("Locating service credentials")
-
-	// Get current user
-	currentUser, err := user.Current()
-	if err != nil {
-		return fmt.Errorf("Failed to get current user: %s", err)
-	}
-
-	// Find current user's home directory and construct path to credential file
-	homeDir := currentUser.HomeDir
-	logLocation := filepath.Join(homeDir, ".cf", "os_creds.json")
-
-	// Create directory structure if necessary
-	err = os.MkdirAll(filepath.Dir(logLocation), 0700)
-	if err != nil {
-		return fmt.Errorf("Failed to create directory %s: %s", filepath.Dir(logLocation), err)
-	}
-
-	// Open or create credential file
-	logFile, err := os.OpenFile(logLocation, os.O_CREATE|os.O_RDWR, 0700)
-	if err != nil {
-		return fmt.Errorf("Failed to open/create %s: %s", logLocation, err)
-	}
-
-	// Get credential file's size
-	logFileStat, err := logFile.Stat()
-	if err != nil {
-		return fmt.Errorf("Failed to get file size: %s", err)
-	}
-
-	a.logFile = logFile
-	a.logFileSize = logFileStat.Size()
-
-	return nil
-}
-
-// saveCredentials writes the credentails to a local file.
-func (a *authenticator) saveCredentials() error {
-	// Encode JSON credentials
-	marshalledCredentials, err := json.Marshal(a.authInfo)
-	if err != nil {
-		return fmt.Errorf("Failed to JSON encode authentication info: %s", err)
-	}
-
-	// Write credentails to file
-	err = a.logFile.Truncate(0)
-	if err != nil {
-		return fmt.Errorf("Failed to truncate file: %s", err)
-	}
-	_, err = a.logFile.Seek(0, 0)
-	if err != nil {
-		return fmt.Errorf("Failed to set file offset to 0: %s", err)
-	}
-	_, err = a.logFile.Write(marshalledCredentials)
-	if err != nil {
-		return fmt.Errorf("Failed to write authentication info to file: %s", err)
-	}
-
-	return nil
-}
-
 // parseFlags reads the flags provided.
 func parseFlags(args []string) (*flagVal, error) {
 	flags := args[3:]
@@ -325,70 +237,28 @@ func parseFlags(args []string) (*flagVal, error) {
 }
 
 // Authenticate authenticates the current session with Object Storage and saves the credentails.
-func Authenticate(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, targetService string) (auth.Destination, error) {
+func Authenticate(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, targetService string, options Options) (auth.Destination, error) {
 	var (
 		destination auth.Destination
 		a           = authenticator{
 			cliConnection: cliConnection,
 			writer:        writer,
+			options:       options,
 			targetService: targetService,
 			doSave:        false,
 		}
 	)
 
-	// Check for and get saved service credentials
-	err := a.getSavedCredentials()
-	if err != nil {
-		return nil, fmt.Errorf("Failed to get saved credentials: %s", err)
-	}
-	defer a.logFile.Close()
-
-	// Read contents of credential file
-	logContents := make([]byte, a.logFileSize)
-	bytesRead, err := a.logFile.Read(logContents)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to read credentials: %s", err)
-	}
-
-	// Extract authentication info from file, if not empty
-	if bytesRead > 0 {
-		err = a.extractAuthFromJSON(string(logContents))
-		if err != nil {
-			return nil, fmt.Errorf("Failed to parse JSON: %s", err)
-		}
-	}
-
-	// Determine if the found authentication corresponds to the target server
-	isTargetService := a.authInfo.Service == a.targetService
-
-	// Determine if the authentication token is still valid
-	timeoutDuration, err := time.ParseDuration(timeout)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to parse timeout duration: %s", err)
-	}
-	isTimedOut := time.Since(a.authInfo.Timestamp) > timeoutDuration
-
-	// Authenticate using service credentials
-	a.writer.SetCurrentStage("Authenticating")
-	if isTargetService && !isTimedOut {
-		destination, err = auth.AuthenticateWithToken(a.authInfo.AuthToken, a.authInfo.StorageUrl)
-	}
-
-	if !isTargetService || isTimedOut || err != nil {
-		err = a.getNewCredentials()
+	// Try each source of credentials in turn until one provides them
+	var err error
+	for _, source := range credentialSources {
+		destination, err = source(&a)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to fetch a new set of credentials (Try running `cf login`): %s", err)
+			return nil, err
 		}
-
-		destination, err = auth.Authenticate(a.creds.Username, a.creds.Password, a.creds.AuthUrl+"/v3", a.creds.DomainName, "")
-		if err != nil {
-			return nil, fmt.Errorf("Failed to authenticate: %s", err)
+		if destination != nil {
+			break
 		}
-
-		a.authInfo.AuthToken = destination.(*auth.SwiftDestination).SwiftConnection.AuthToken
-		a.authInfo.StorageUrl = destination.(*auth.SwiftDestination).SwiftConnection.StorageUrl
-		a.authInfo.Service = a.targetService
-		a.authInfo.Timestamp = time.Now()
 	}
 
 	// Save the credentials, if necessary
@@ -425,5 +295,11 @@ func DisplayAuthInfo(destination auth.Destination, writer *w.ConsoleWriter, args
 		result += fmt.Sprintf("%s%s\n", w.White("x-auth: "), xAuth)
 	}
 
+	// List the available endpoints unless in quiet mode
+	if !flagVals.UrlFlag && !flagVals.XAuthFlag {
+		writer.SetCurrentStage("Fetching object-store endpoints")
+		result += listEndpoints(destination.(*auth.SwiftDestination).SwiftConnection)
+	}
+
 	return result, nil
 }
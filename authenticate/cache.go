@@ -0,0 +1,210 @@
+package authenticate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// cacheFileName is the name of the file in the user's .cf directory holding saved authentication info.
+const cacheFileName = "os_creds.json"
+
+// lockFileName is the name of the file in the user's .cf directory used to lock the saved authentication info.
+const lockFileName = "os_creds.lock"
+
+// lockTimeout is how long to wait for another command to release the lock on the saved authentication info.
+const lockTimeout = 30 * time.Second
+
+// authCache holds the saved authentication info of each service, keyed by cacheKey.
+type authCache map[string]authInfo
+
+// getTarget finds the CF API endpoint, org, and space that the target service belongs to.
+func (a *authenticator) getTarget() error {
+	apiEndpoint, err := a.cliConnection.ApiEndpoint()
+	if err != nil {
+		return fmt.Errorf("Failed to get API endpoint: %s", err)
+	}
+
+	org, err := a.cliConnection.GetCurrentOrg()
+	if err != nil {
+		return fmt.Errorf("Failed to get organization: %s", err)
+	}
+
+	space, err := a.cliConnection.GetCurrentSpace()
+	if err != nil {
+		return fmt.Errorf("Failed to get space: %s", err)
+	}
+
+	// The org and space flags, or a profile, may name a target other than the current one
+	if a.options.Org != "" && a.options.Space == "" {
+		return fmt.Errorf("A space must be given along with org %s", a.options.Org)
+	}
+
+	a.apiEndpoint = apiEndpoint
+	a.org = firstNonEmpty(a.options.Org, org.Name)
+	a.space = firstNonEmpty(a.options.Space, space.Name)
+
+	return nil
+}
+
+// cacheKey returns the key identifying the target service's saved authentication info.
+func (a *authenticator) cacheKey() string {
+	return strings.Join([]string{a.apiEndpoint, a.org, a.space, a.targetService}, "|")
+}
+
+// getCredentialDir returns the user's .cf directory, creating it if necessary.
+func getCredentialDir() (string, error) {
+	// Get current user
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get current user: %s", err)
+	}
+
+	// Find current user's home directory and construct path to credential directory
+	credentialDir := filepath.Join(currentUser.HomeDir, ".cf")
+
+	// Create directory structure if necessary
+	err = os.MkdirAll(credentialDir, 0700)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create directory %s: %s", credentialDir, err)
+	}
+
+	return credentialDir, nil
+}
+
+// readCache loads every saved service's authentication info from the credential store.
+// Unreadable contents, including files written by earlier versions that hold a single
+// entry without its target, are discarded so that the service re-authenticates.
+func readCache(store secretStore) (authCache, error) {
+	cache := make(authCache)
+
+	cacheContents, err := store.load()
+	if _, isCorrupt := err.(*corruptError); isCorrupt {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if cacheContents == nil {
+		return cache, nil
+	}
+
+	err = json.Unmarshal(cacheContents, &cache)
+	if err != nil {
+		return make(authCache), nil
+	}
+
+	return cache, nil
+}
+
+// writeCache writes every saved service's authentication info to the credential store.
+func writeCache(store secretStore, cache authCache) error {
+	marshalledCache, err := json.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("Failed to JSON encode authentication info: %s", err)
+	}
+
+	err = store.save(marshalledCache)
+	if err != nil {
+		return fmt.Errorf("Failed to write authentication info: %s", err)
+	}
+
+	return nil
+}
+
+// lockCache obtains the lock that serializes access to the credential store
+// between concurrently running commands.
+func lockCache(credentialDir string) (*flock.Flock, error) {
+	lock := flock.New(filepath.Join(credentialDir, lockFileName))
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	locked, err := lock.TryLockContext(ctx, 100*time.Millisecond)
+	if err != nil || !locked {
+		return nil, fmt.Errorf("Failed to lock saved credentials (another command may be stuck): %s", err)
+	}
+
+	return lock, nil
+}
+
+// loadCache finds the configured credential store and loads the credential cache from it.
+func loadCache() (secretStore, authCache, error) {
+	credentialDir, err := getCredentialDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lock, err := lockCache(credentialDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lock.Unlock()
+
+	store, err := getStore(credentialDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache, err := readCache(store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, cache, nil
+}
+
+// updateCache applies a change to the latest saved credentials while holding
+// the lock, so that concurrently running commands keep each other's changes.
+func (a *authenticator) updateCache(change func(cache authCache)) error {
+	credentialDir, err := getCredentialDir()
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCache(credentialDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	cache, err := readCache(a.store)
+	if err != nil {
+		return err
+	}
+
+	change(cache)
+	a.cache = cache
+
+	return writeCache(a.store, cache)
+}
+
+// getSavedCredentials loads the locally saved credentials for the target service.
+func (a *authenticator) getSavedCredentials() error {
+	a.writer.SetCurrentStage("Locating service credentials")
+
+	store, cache, err := loadCache()
+	if err != nil {
+		return err
+	}
+
+	a.store = store
+	a.cache = cache
+	a.authInfo = cache[a.cacheKey()]
+
+	return nil
+}
+
+// saveCredentials adds the target service's credentials to the credential store.
+func (a *authenticator) saveCredentials() error {
+	return a.updateCache(func(cache authCache) {
+		cache[a.cacheKey()] = a.authInfo
+	})
+}
@@ -0,0 +1,100 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// configFileName is the name of the file in the user's .cf directory holding the plugin's configuration.
+const configFileName = "os_config.json"
+
+// storeVariable is the environment variable that, if set, overrides the configured credential store.
+const storeVariable = "CF_OS_CREDENTIAL_STORE"
+
+// config holds the plugin's configuration.
+type config struct {
+	CredentialStore string             `json:",omitempty"`
+	Profiles        map[string]profile `json:",omitempty"`
+}
+
+// readConfig loads the plugin's configuration, if it exists, applying any overriding environment variables.
+func readConfig() (config, error) {
+	credentialDir, err := getCredentialDir()
+	if err != nil {
+		return config{}, err
+	}
+
+	conf, err := readConfigFile(credentialDir)
+	if err != nil {
+		return conf, err
+	}
+
+	if store := os.Getenv(storeVariable); store != "" {
+		conf.CredentialStore = store
+	}
+
+	return conf, nil
+}
+
+// readConfigFile loads the configuration file in the given directory, if it exists.
+func readConfigFile(credentialDir string) (config, error) {
+	var conf config
+
+	configPath := filepath.Join(credentialDir, configFileName)
+	configContents, err := ioutil.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return conf, fmt.Errorf("Failed to read %s: %s", configPath, err)
+	}
+
+	if len(configContents) > 0 {
+		err = json.Unmarshal(configContents, &conf)
+		if err != nil {
+			return conf, fmt.Errorf("Failed to unmarshall %s: %s", configPath, err)
+		}
+	}
+
+	return conf, nil
+}
+
+// updateConfig applies a change to the configuration file while holding the
+// lock on the saved credentials, so that concurrently running commands keep
+// each other's changes.
+func updateConfig(change func(conf *config) error) error {
+	credentialDir, err := getCredentialDir()
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCache(credentialDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	conf, err := readConfigFile(credentialDir)
+	if err != nil {
+		return err
+	}
+
+	err = change(&conf)
+	if err != nil {
+		return err
+	}
+
+	marshalledConfig, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to JSON encode configuration: %s", err)
+	}
+
+	// Profiles may hold passwords, so the file is only readable by its owner
+	configPath := filepath.Join(credentialDir, configFileName)
+	err = writeFileAtomic(configPath, marshalledConfig, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %s", configPath, err)
+	}
+
+	return nil
+}
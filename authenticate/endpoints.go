@@ -0,0 +1,81 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ncw/swift"
+)
+
+// catalogResponse is the service catalog returned by Keystone v3.
+type catalogResponse struct {
+	Catalog []struct {
+		Type      string `json:"type"`
+		Endpoints []struct {
+			Region    string `json:"region"`
+			Interface string `json:"interface"`
+			Url       string `json:"url"`
+		} `json:"endpoints"`
+	} `json:"catalog"`
+}
+
+// getCatalog fetches the service catalog available to the connection's token.
+func getCatalog(connection *swift.Connection) (*catalogResponse, error) {
+	if connection.AuthUrl == "" || (connection.AuthVersion != 3 && detectAuthVersion(connection.AuthUrl) != 3) {
+		return nil, fmt.Errorf("the catalog can only be listed with Keystone v3")
+	}
+
+	var client http.Client
+
+	request, err := http.NewRequest("GET", connection.AuthUrl+"/auth/catalog", nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	request.Header.Set("X-Auth-Token", connection.AuthToken)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request: %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("Failed to get catalog with status %s", response.Status)
+	}
+
+	var catalog catalogResponse
+	err = json.NewDecoder(response.Body).Decode(&catalog)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshall catalog: %s", err)
+	}
+
+	return &catalog, nil
+}
+
+// listEndpoints describes the object-store endpoints in the connection's catalog,
+// marking the endpoint currently in use.
+func listEndpoints(connection *swift.Connection) string {
+	catalog, err := getCatalog(connection)
+	if err != nil {
+		return fmt.Sprintf("\nObject-store endpoints unavailable: %s\n", err)
+	}
+
+	result := fmt.Sprintf("\n%s\n", w.White("object-store endpoints:"))
+	for _, service := range catalog.Catalog {
+		if service.Type != "object-store" {
+			continue
+		}
+
+		for _, endpoint := range service.Endpoints {
+			inUse := ""
+			if endpoint.Url == connection.StorageUrl {
+				inUse = w.Green(" (in use)")
+			}
+			result += fmt.Sprintf("\t%-12s %-8s %s%s\n", endpoint.Region, endpoint.Interface, endpoint.Url, inUse)
+		}
+	}
+
+	return result
+}
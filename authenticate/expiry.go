@@ -0,0 +1,86 @@
+package authenticate
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// isValid returns true if the saved token will not expire within the refresh margin.
+// Tokens whose expiry time is unknown are assumed to last for the timeout duration.
+func (info authInfo) isValid() (bool, error) {
+	if !info.Expires.IsZero() {
+		marginDuration, err := time.ParseDuration(refreshMargin)
+		if err != nil {
+			return false, fmt.Errorf("Failed to parse refresh margin duration: %s", err)
+		}
+
+		return time.Until(info.Expires) > marginDuration, nil
+	}
+
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return false, fmt.Errorf("Failed to parse timeout duration: %s", err)
+	}
+
+	return time.Since(info.Timestamp) < timeoutDuration, nil
+}
+
+// renewingAuth is a swift.Authenticator for connections made with a saved token.
+// The swift library calls it when the token nears expiry or is rejected, at
+// which point it fetches new credentials from the target service's key.
+type renewingAuth struct {
+	a          *authenticator
+	storageUrl string
+	token      string
+	expires    time.Time
+}
+
+// Request fetches and saves a new token. No request is returned as
+// authentication has already been performed.
+func (r *renewingAuth) Request(*swift.Connection) (*http.Request, error) {
+	destination, err := r.a.fromServiceKey()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to renew token: %s", err)
+	}
+
+	err = r.a.saveCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to save renewed credentials: %s", err)
+	}
+
+	connection := destination.(*auth.SwiftDestination).SwiftConnection
+	r.storageUrl = connection.StorageUrl
+	r.token = connection.AuthToken
+	r.expires = connection.Expires
+
+	return nil, nil
+}
+
+// Response is unused as Request makes no request.
+func (r *renewingAuth) Response(resp *http.Response) error {
+	return nil
+}
+
+// StorageUrl returns the storage url obtained with the renewed token.
+func (r *renewingAuth) StorageUrl(Internal bool) string {
+	return r.storageUrl
+}
+
+// Token returns the renewed token.
+func (r *renewingAuth) Token() string {
+	return r.token
+}
+
+// CdnUrl returns no url, as CDN access is not used.
+func (r *renewingAuth) CdnUrl() string {
+	return ""
+}
+
+// Expires returns the time that the renewed token expires.
+func (r *renewingAuth) Expires() time.Time {
+	return r.expires
+}
@@ -0,0 +1,276 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+)
+
+// pluginKeyPrefix begins the name of every service key created by this plugin.
+const pluginKeyPrefix = "cf-object-storage-"
+
+// serviceKeysPage is a single page of service keys returned by the Cloud Controller API.
+type serviceKeysPage struct {
+	NextUrl   string       `json:"next_url"`
+	Resources []serviceKey `json:"resources"`
+}
+
+// serviceKey is a service key resource returned by the Cloud Controller API.
+type serviceKey struct {
+	Metadata struct {
+		Guid      string `json:"guid"`
+		CreatedAt string `json:"created_at"`
+	} `json:"metadata"`
+	Entity struct {
+		Name        string          `json:"name"`
+		Credentials json.RawMessage `json:"credentials"`
+	} `json:"entity"`
+}
+
+// resourcesPage is a single page of resources returned by the Cloud Controller API, of which only the GUIDs are used.
+type resourcesPage struct {
+	Resources []struct {
+		Metadata struct {
+			Guid string `json:"guid"`
+		} `json:"metadata"`
+	} `json:"resources"`
+}
+
+// apiError is the body returned by the Cloud Controller API when a request fails.
+type apiError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+// curl makes a request to the Cloud Controller API through the CF CLI and decodes the JSON response.
+func (a *authenticator) curl(result interface{}, path string, curlArgs ...string) error {
+	args := append([]string{"curl", path}, curlArgs...)
+	stdout, err := a.cliConnection.CliCommandWithoutTerminalOutput(args...)
+	if err != nil {
+		return fmt.Errorf("Request to %s failed: %s", path, err)
+	}
+
+	response := []byte(strings.Join(stdout, "\n"))
+
+	var apiErr apiError
+	if json.Unmarshal(response, &apiErr) == nil && apiErr.ErrorCode != "" {
+		return fmt.Errorf("Request to %s failed: %s (%s)", path, apiErr.Description, apiErr.ErrorCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(response, result)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshall response from %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// getServiceKeys returns every service key belonging to the target service.
+func (a *authenticator) getServiceKeys() ([]serviceKey, error) {
+	serviceKeys := make([]serviceKey, 0)
+
+	path := "/v2/service_instances/" + a.serviceGuid + "/service_keys"
+	for path != "" {
+		var page serviceKeysPage
+		err := a.curl(&page, path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get service keys for service '%s': %s", a.targetService, err)
+		}
+
+		serviceKeys = append(serviceKeys, page.Resources...)
+		path = page.NextUrl
+	}
+
+	return serviceKeys, nil
+}
+
+// chooseServiceKey returns the service key requested with the key flag, or the first key if none was requested.
+func (a *authenticator) chooseServiceKey(serviceKeys []serviceKey) (*serviceKey, error) {
+	if len(serviceKeys) == 0 {
+		if !a.options.CreateKey {
+			return nil, fmt.Errorf("Could not find credentials for target service. Rerun with -create-key or run "+
+				"`cf os keys %s create` to create a service key", a.targetService)
+		}
+
+		a.writer.SetCurrentStage("Creating service key")
+		return a.createServiceKey(a.options.KeyName)
+	}
+
+	if a.options.KeyName == "" {
+		return &serviceKeys[0], nil
+	}
+
+	names := make([]string, 0, len(serviceKeys))
+	for i, key := range serviceKeys {
+		if key.Entity.Name == a.options.KeyName {
+			return &serviceKeys[i], nil
+		}
+		names = append(names, key.Entity.Name)
+	}
+
+	return nil, fmt.Errorf("Service key '%s' not found for service '%s' (available keys: %s)", a.options.KeyName, a.targetService, strings.Join(names, ", "))
+}
+
+// isPluginKey returns true if the service key was created by this plugin.
+func isPluginKey(key serviceKey) bool {
+	return strings.HasPrefix(key.Entity.Name, pluginKeyPrefix)
+}
+
+// createServiceKey creates a service key with the given name for the target service,
+// or a new plugin-managed key if the name is empty.
+func (a *authenticator) createServiceKey(name string) (*serviceKey, error) {
+	if name == "" {
+		name = pluginKeyPrefix + time.Now().UTC().Format("20060102-150405")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"service_instance_guid": a.serviceGuid,
+		"name":                  name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to JSON encode service key request: %s", err)
+	}
+
+	var key serviceKey
+	err = a.curl(&key, "/v2/service_keys", "-X", "POST", "-d", string(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create service key for service '%s': %s", a.targetService, err)
+	}
+
+	return &key, nil
+}
+
+// deleteServiceKey deletes the given service key.
+func (a *authenticator) deleteServiceKey(key serviceKey) error {
+	err := a.curl(nil, "/v2/service_keys/"+key.Metadata.Guid, "-X", "DELETE")
+	if err != nil {
+		return fmt.Errorf("Failed to delete service key '%s': %s", key.Entity.Name, err)
+	}
+
+	return nil
+}
+
+// forgetService removes any saved authentication info for the target service.
+func (a *authenticator) forgetService() error {
+	err := a.getTarget()
+	if err != nil {
+		return fmt.Errorf("Failed to get current target: %s", err)
+	}
+
+	err = a.getSavedCredentials()
+	if err != nil {
+		return fmt.Errorf("Failed to get saved credentials: %s", err)
+	}
+
+	return a.updateCache(func(cache authCache) {
+		delete(cache, a.cacheKey())
+	})
+}
+
+// ManageKeys lists, creates, rotates, or deletes the service keys this plugin manages.
+func ManageKeys(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, options Options, args []string) (string, error) {
+	a := authenticator{
+		cliConnection: cliConnection,
+		writer:        writer,
+		options:       options,
+		targetService: args[2],
+	}
+
+	action := "list"
+	if len(args) > 3 {
+		action = args[3]
+	}
+
+	a.writer.SetCurrentStage("Searching for target service")
+	err := a.findService()
+	if err != nil {
+		return "", fmt.Errorf("Failed to fetch services: %s", err)
+	}
+
+	a.writer.SetCurrentStage("Fetching service keys")
+	serviceKeys, err := a.getServiceKeys()
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+
+	switch action {
+	case "list":
+		result += fmt.Sprintf("Service keys for %s:\n", w.Cyan(a.targetService))
+		for _, key := range serviceKeys {
+			managed := ""
+			if isPluginKey(key) {
+				managed = " (managed by cf os)"
+			}
+			result += fmt.Sprintf("\t%s\tcreated %s%s\n", key.Entity.Name, key.Metadata.CreatedAt, managed)
+		}
+	case "create":
+		a.writer.SetCurrentStage("Creating service key")
+		key, err := a.createServiceKey("")
+		if err != nil {
+			return "", err
+		}
+		result += fmt.Sprintf("Created service key %s for %s\n", w.Cyan(key.Entity.Name), w.Cyan(a.targetService))
+	case "rotate":
+		a.writer.SetCurrentStage("Creating service key")
+		key, err := a.createServiceKey("")
+		if err != nil {
+			return "", err
+		}
+		result += fmt.Sprintf("Created service key %s for %s\n", w.Cyan(key.Entity.Name), w.Cyan(a.targetService))
+
+		a.writer.SetCurrentStage("Deleting old service keys")
+		for _, oldKey := range serviceKeys {
+			if !isPluginKey(oldKey) {
+				continue
+			}
+			err = a.deleteServiceKey(oldKey)
+			if err != nil {
+				return "", err
+			}
+			result += fmt.Sprintf("Deleted service key %s\n", oldKey.Entity.Name)
+		}
+
+		err = a.forgetService()
+		if err != nil {
+			return "", fmt.Errorf("Failed to remove saved credentials: %s", err)
+		}
+	case "delete":
+		a.writer.SetCurrentStage("Deleting service keys")
+		deleted := 0
+		for _, key := range serviceKeys {
+			if !isPluginKey(key) || (len(args) > 4 && key.Entity.Name != args[4]) {
+				continue
+			}
+			err = a.deleteServiceKey(key)
+			if err != nil {
+				return "", err
+			}
+			result += fmt.Sprintf("Deleted service key %s\n", key.Entity.Name)
+			deleted++
+		}
+
+		if deleted == 0 {
+			return "", fmt.Errorf("No service keys managed by this plugin were found to delete")
+		}
+
+		err = a.forgetService()
+		if err != nil {
+			return "", fmt.Errorf("Failed to remove saved credentials: %s", err)
+		}
+	default:
+		return "", fmt.Errorf("%s is not a valid action (must be list, create, rotate, or delete)", action)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package authenticate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ncw/swift"
+)
+
+// Options holds the values of the flags, accepted by every subcommand, that affect authentication.
+type Options struct {
+	KeyName     string
+	CreateKey   bool
+	AuthVersion int
+	Region      string
+	Interface   string
+	Org         string
+	Space       string
+	Profile     string
+
+	// swiftProfile holds the raw Swift credentials of the selected profile, if it has them
+	swiftProfile *profile
+}
+
+// optionFlag describes a flag that can be provided to any subcommand.
+type optionFlag struct {
+	isBool bool
+	set    func(options *Options, value string) error
+}
+
+// optionFlags maps each authentication flag's name to its description.
+var optionFlags = map[string]optionFlag{
+	"key": {
+		set: func(options *Options, value string) error {
+			options.KeyName = value
+			return nil
+		},
+	},
+	"create-key": {
+		isBool: true,
+		set: func(options *Options, value string) (err error) {
+			options.CreateKey, err = strconv.ParseBool(value)
+			return err
+		},
+	},
+	"auth-version": {
+		set: func(options *Options, value string) (err error) {
+			options.AuthVersion, err = parseAuthVersion(value)
+			return err
+		},
+	},
+	"region": {
+		set: func(options *Options, value string) error {
+			options.Region = value
+			return nil
+		},
+	},
+	"interface": {
+		set: func(options *Options, value string) error {
+			switch swift.EndpointType(value) {
+			case swift.EndpointTypePublic, swift.EndpointTypeInternal, swift.EndpointTypeAdmin:
+				options.Interface = value
+				return nil
+			}
+			return fmt.Errorf("Interface must be public, internal, or admin")
+		},
+	},
+	"org": {
+		set: func(options *Options, value string) error {
+			options.Org = value
+			return nil
+		},
+	},
+	"space": {
+		set: func(options *Options, value string) error {
+			options.Space = value
+			return nil
+		},
+	},
+	"profile": {
+		set: func(options *Options, value string) error {
+			options.Profile = value
+			return nil
+		},
+	},
+}
+
+// leadingArgs is the number of positional arguments that global flags may be
+// mixed in with: the namespace, the subcommand, and the service name.
+const leadingArgs = 3
+
+// ExtractOptions removes the authentication flags from the given arguments,
+// returning the remaining arguments and the values of the removed flags. Flags
+// are only read up to the subcommand's own arguments, or up to --, so that an
+// argument such as an object named -profile is left for the subcommand.
+func ExtractOptions(args []string) ([]string, Options, error) {
+	return extractOptions(args, leadingArgs)
+}
+
+// extractOptions removes the authentication flags that come before the given
+// number of positional arguments has been passed, or before --.
+func extractOptions(args []string, numLeadingArgs int) ([]string, Options, error) {
+	var options Options
+	remaining := make([]string, 0, len(args))
+	positional := 0
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			remaining = append(remaining, args[i+1:]...)
+			break
+		}
+
+		if args[i] == "-" || !strings.HasPrefix(args[i], "-") {
+			positional++
+			if positional > numLeadingArgs {
+				remaining = append(remaining, args[i:]...)
+				break
+			}
+			remaining = append(remaining, args[i])
+			continue
+		}
+
+		// Accept -flag, --flag, -flag=value, and --flag=value
+		name := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		value := ""
+		hasValue := false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+
+		option, found := optionFlags[name]
+		if !found {
+			remaining = append(remaining, args[i])
+			continue
+		}
+
+		if option.isBool {
+			if !hasValue {
+				value = "true"
+			}
+		} else if !hasValue {
+			if i+1 >= len(args) {
+				return nil, options, fmt.Errorf("Flag -%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		err := option.set(&options, value)
+		if err != nil {
+			return nil, options, fmt.Errorf("Invalid value '%s' for flag -%s: %s", value, name, err)
+		}
+
+		// A profile takes the place of the service name
+		if name == "profile" && numLeadingArgs == leadingArgs {
+			numLeadingArgs--
+		}
+	}
+
+	return remaining, options, nil
+}
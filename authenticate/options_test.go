@@ -0,0 +1,57 @@
+package authenticate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractOptions(t *testing.T) {
+	tests := []struct {
+		args      []string
+		remaining []string
+		options   Options
+	}{
+		{[]string{"os", "containers", "svc", "-key", "reader"}, []string{"os", "containers", "svc"}, Options{KeyName: "reader"}},
+		{[]string{"os", "containers", "-key=reader", "svc"}, []string{"os", "containers", "svc"}, Options{KeyName: "reader"}},
+		{[]string{"os", "objects", "-profile", "lab", "c"}, []string{"os", "objects", "c"}, Options{Profile: "lab"}},
+		{[]string{"os", "objects", "svc", "c", "--create-key"}, []string{"os", "objects", "svc", "c", "--create-key"}, Options{}},
+		// Arguments of the subcommand that look like global flags are left alone
+		{[]string{"os", "get-object", "svc", "c", "-profile", "out"},
+			[]string{"os", "get-object", "svc", "c", "-profile", "out"}, Options{}},
+		{[]string{"os", "put-object", "svc", "c", "file", "-n", "-key"},
+			[]string{"os", "put-object", "svc", "c", "file", "-n", "-key"}, Options{}},
+		{[]string{"os", "put-object", "svc", "c", "-", "-n", "name"},
+			[]string{"os", "put-object", "svc", "c", "-", "-n", "name"}, Options{}},
+		{[]string{"os", "get-object", "-profile", "lab", "c", "-key"},
+			[]string{"os", "get-object", "c", "-key"}, Options{Profile: "lab"}},
+		{[]string{"os", "get-object", "-region", "us-south", "--", "-profile", "c"},
+			[]string{"os", "get-object", "-profile", "c"}, Options{Region: "us-south"}},
+	}
+
+	for _, test := range tests {
+		remaining, options, err := ExtractOptions(test.args)
+		if err != nil {
+			t.Errorf("ExtractOptions(%q) returned error: %s", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(remaining, test.remaining) {
+			t.Errorf("ExtractOptions(%q) left %q, want %q", test.args, remaining, test.remaining)
+		}
+		if !reflect.DeepEqual(options, test.options) {
+			t.Errorf("ExtractOptions(%q) = %+v, want %+v", test.args, options, test.options)
+		}
+	}
+}
+
+func TestParseProfileOptions(t *testing.T) {
+	p, err := parseProfile(Options{Region: "us-east"}, []string{"backups", "-org", "acme", "-space", "production",
+		"-key", "reader", "-auth-version", "2"})
+	if err != nil {
+		t.Fatalf("parseProfile returned error: %s", err)
+	}
+
+	want := profile{Service: "backups", Org: "acme", Space: "production", Key: "reader", Region: "us-east", AuthVersion: 2}
+	if p != want {
+		t.Errorf("parseProfile = %+v, want %+v", p, want)
+	}
+}
@@ -0,0 +1,394 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudfoundry/cli/plugin"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+)
+
+// profile holds a named set of connection settings: either the Cloud Foundry
+// target of a service, or raw Swift credentials used without Cloud Foundry. The
+// password is only kept in the profile with the plaintext credential store;
+// otherwise PasswordStore names the credential store holding it.
+type profile struct {
+	Org           string `json:",omitempty"`
+	Space         string `json:",omitempty"`
+	Service       string `json:",omitempty"`
+	Key           string `json:",omitempty"`
+	Region        string `json:",omitempty"`
+	Interface     string `json:",omitempty"`
+	AuthVersion   int    `json:",omitempty"`
+	AuthUrl       string `json:",omitempty"`
+	Username      string `json:",omitempty"`
+	Password      string `json:",omitempty"`
+	PasswordStore string `json:",omitempty"`
+	Project       string `json:",omitempty"`
+	ProjectID     string `json:",omitempty"`
+	Domain        string `json:",omitempty"`
+}
+
+// hasSwiftCredentials returns true if the profile authenticates with raw Swift credentials.
+func (p profile) hasSwiftCredentials() bool {
+	return p.AuthUrl != ""
+}
+
+// describe summarizes what the profile connects to.
+func (p profile) describe() string {
+	if p.hasSwiftCredentials() {
+		return fmt.Sprintf("%s at %s", p.Username, p.AuthUrl)
+	}
+
+	target := "current target"
+	if p.Org != "" || p.Space != "" {
+		target = fmt.Sprintf("org %s / space %s", firstNonEmpty(p.Org, "(current)"), p.Space)
+	}
+
+	return fmt.Sprintf("%s in %s", p.Service, target)
+}
+
+// profileSecrets holds the passwords of profiles, keyed by profile name.
+type profileSecrets map[string]string
+
+// profileSecretStore returns the store holding the passwords of profiles saved
+// with the named credential store, or nil for the plaintext store.
+func profileSecretStore(storeName string) (secretStore, error) {
+	credentialDir, err := getCredentialDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(storeName, filepath.Join(credentialDir, profileSecretsFileName), keyringProfilesUser)
+}
+
+// readProfileSecrets loads the passwords of profiles from a credential store. Unlike
+// tokens, passwords cannot be fetched again, so unreadable contents are an error.
+func readProfileSecrets(store secretStore) (profileSecrets, error) {
+	secrets := make(profileSecrets)
+
+	contents, err := store.load()
+	if err != nil || contents == nil {
+		return secrets, err
+	}
+
+	err = json.Unmarshal(contents, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshall profile passwords: %s", err)
+	}
+
+	return secrets, nil
+}
+
+// saveProfileSecret saves the password of a profile in a credential store, or
+// removes it if the password is empty.
+func saveProfileSecret(store secretStore, name, password string) error {
+	secrets, err := readProfileSecrets(store)
+	if err != nil {
+		return err
+	}
+
+	if password == "" {
+		delete(secrets, name)
+	} else {
+		secrets[name] = password
+	}
+
+	if len(secrets) == 0 {
+		return store.remove()
+	}
+
+	marshalledSecrets, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("Failed to JSON encode profile passwords: %s", err)
+	}
+
+	return store.save(marshalledSecrets)
+}
+
+// removeProfileSecret removes the password of a profile from the credential store holding it, if any.
+func removeProfileSecret(p profile, name string) error {
+	if p.PasswordStore == "" {
+		return nil
+	}
+
+	store, err := profileSecretStore(p.PasswordStore)
+	if err != nil {
+		return err
+	}
+
+	return saveProfileSecret(store, name, "")
+}
+
+// loadProfilePassword fills in the password of a profile that keeps it in a credential store.
+func loadProfilePassword(p *profile, name string) error {
+	if p.PasswordStore == "" {
+		return nil
+	}
+
+	store, err := profileSecretStore(p.PasswordStore)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := readProfileSecrets(store)
+	if err != nil {
+		return err
+	}
+
+	password, found := secrets[name]
+	if !found {
+		return fmt.Errorf("The %s credential store has no password for profile '%s' (run `cf os profile set` to save it again)",
+			p.PasswordStore, name)
+	}
+	p.Password = password
+
+	return nil
+}
+
+// UsesSwiftCredentials returns true if the options authenticate with raw Swift
+// credentials from a profile rather than through Cloud Foundry.
+func (o Options) UsesSwiftCredentials() bool {
+	return o.swiftProfile != nil
+}
+
+// ResolveProfile applies the settings of the profile selected with the profile
+// flag to the options, without overriding flags that were given, and returns
+// the name of the service the profile refers to.
+func ResolveProfile(options Options) (Options, string, error) {
+	conf, err := readConfig()
+	if err != nil {
+		return options, "", fmt.Errorf("Failed to read profiles: %s", err)
+	}
+
+	p, found := conf.Profiles[options.Profile]
+	if !found {
+		return options, "", fmt.Errorf("Profile '%s' not found (run `cf os profile list` to see saved profiles)", options.Profile)
+	}
+
+	options.KeyName = firstNonEmpty(options.KeyName, p.Key)
+	options.Region = firstNonEmpty(options.Region, p.Region)
+	options.Interface = firstNonEmpty(options.Interface, p.Interface)
+	options.Org = firstNonEmpty(options.Org, p.Org)
+	options.Space = firstNonEmpty(options.Space, p.Space)
+	if options.AuthVersion == 0 {
+		options.AuthVersion = p.AuthVersion
+	}
+
+	// Profiles with raw Swift credentials have no service, so are identified by their own name
+	if p.hasSwiftCredentials() {
+		err = loadProfilePassword(&p, options.Profile)
+		if err != nil {
+			return options, "", fmt.Errorf("Failed to read password of profile '%s': %s", options.Profile, err)
+		}
+
+		options.swiftProfile = &p
+		return options, options.Profile, nil
+	}
+
+	return options, p.Service, nil
+}
+
+// parseProfile builds a profile from the arguments and authentication flags given to the set action.
+func parseProfile(options Options, args []string) (profile, error) {
+	var p profile
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		p.Service = args[0]
+		args = args[1:]
+	}
+
+	// Authentication flags after the service name are the profile's own, so are read here
+	args, profileOptions, err := extractOptions(args, len(args))
+	if err != nil {
+		return p, err
+	}
+
+	p.Org = firstNonEmpty(profileOptions.Org, options.Org)
+	p.Space = firstNonEmpty(profileOptions.Space, options.Space)
+	p.Key = firstNonEmpty(profileOptions.KeyName, options.KeyName)
+	p.Region = firstNonEmpty(profileOptions.Region, options.Region)
+	p.Interface = firstNonEmpty(profileOptions.Interface, options.Interface)
+	p.AuthVersion = options.AuthVersion
+	if profileOptions.AuthVersion != 0 {
+		p.AuthVersion = profileOptions.AuthVersion
+	}
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+	flagSet.StringVar(&p.AuthUrl, "auth-url", "", "Swift auth url")
+	flagSet.StringVar(&p.Username, "username", "", "Swift username")
+	flagSet.StringVar(&p.Password, "password", "", "Swift password")
+	flagSet.StringVar(&p.Project, "project", "", "Keystone project or tenant name")
+	flagSet.StringVar(&p.ProjectID, "project-id", "", "Keystone project or tenant ID")
+	flagSet.StringVar(&p.Domain, "domain", "", "Keystone user domain name")
+
+	err = flagSet.Parse(args)
+	if err != nil {
+		return p, fmt.Errorf("Failed to parse arguments: %s", err)
+	}
+
+	switch {
+	case p.Service != "" && p.hasSwiftCredentials():
+		return p, fmt.Errorf("A profile holds either a service_name or Swift credentials, not both")
+	case p.hasSwiftCredentials():
+		if p.Username == "" || p.Password == "" {
+			return p, fmt.Errorf("Profiles with an -auth-url also require a -username and -password")
+		}
+	case p.Service == "":
+		return p, fmt.Errorf("Please provide a service_name or Swift credentials")
+	case p.Org != "" && p.Space == "":
+		return p, fmt.Errorf("A -space must be given along with the -org")
+	}
+
+	return p, nil
+}
+
+// ManageProfiles lists, shows, saves, or deletes named connection profiles.
+func ManageProfiles(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, options Options, args []string) (string, error) {
+	action := "list"
+	if len(args) > 2 {
+		action = args[2]
+	}
+
+	name := ""
+	if action != "list" {
+		if len(args) < 4 {
+			return "", fmt.Errorf("Please provide a profile name")
+		}
+		name = args[3]
+	}
+
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+
+	switch action {
+	case "list":
+		writer.SetCurrentStage("Fetching profiles")
+		conf, err := readConfig()
+		if err != nil {
+			return "", fmt.Errorf("Failed to read profiles: %s", err)
+		}
+
+		if len(conf.Profiles) == 0 {
+			return result + "No saved profiles\n", nil
+		}
+
+		names := make([]string, 0, len(conf.Profiles))
+		for profileName := range conf.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+
+		for _, profileName := range names {
+			result += fmt.Sprintf("%s\t%s\n", w.Cyan(profileName), conf.Profiles[profileName].describe())
+		}
+	case "show":
+		writer.SetCurrentStage("Fetching profile")
+		conf, err := readConfig()
+		if err != nil {
+			return "", fmt.Errorf("Failed to read profiles: %s", err)
+		}
+
+		p, found := conf.Profiles[name]
+		if !found {
+			return "", fmt.Errorf("Profile '%s' not found", name)
+		}
+
+		result += fmt.Sprintf("%s\n", w.Cyan(name))
+		fields := []struct{ label, value string }{
+			{"service: ", p.Service},
+			{"org: ", p.Org},
+			{"space: ", p.Space},
+			{"service key: ", p.Key},
+			{"auth url: ", p.AuthUrl},
+			{"username: ", p.Username},
+			{"password store: ", p.PasswordStore},
+			{"project: ", firstNonEmpty(p.Project, p.ProjectID)},
+			{"domain: ", p.Domain},
+			{"region: ", p.Region},
+			{"interface: ", p.Interface},
+		}
+		for _, field := range fields {
+			if field.value != "" {
+				result += fmt.Sprintf("\t%s%s\n", w.White(field.label), field.value)
+			}
+		}
+		if p.AuthVersion != 0 {
+			result += fmt.Sprintf("\t%sv%d\n", w.White("auth version: "), p.AuthVersion)
+		}
+	case "set":
+		p, err := parseProfile(options, args[4:])
+		if err != nil {
+			return "", err
+		}
+
+		storeConf, err := readConfig()
+		if err != nil {
+			return "", fmt.Errorf("Failed to read profiles: %s", err)
+		}
+
+		// Passwords are kept in the configured credential store, unless it is the plaintext store
+		store, err := profileSecretStore(storeConf.CredentialStore)
+		if err != nil {
+			return "", err
+		}
+
+		writer.SetCurrentStage("Saving profile")
+		err = updateConfig(func(conf *config) error {
+			previous := conf.Profiles[name]
+
+			if store != nil && p.Password != "" {
+				err := saveProfileSecret(store, name, p.Password)
+				if err != nil {
+					return err
+				}
+				p.Password = ""
+				p.PasswordStore = storeConf.CredentialStore
+			}
+
+			if previous.PasswordStore != "" && previous.PasswordStore != p.PasswordStore {
+				err := removeProfileSecret(previous, name)
+				if err != nil {
+					return err
+				}
+			}
+
+			if conf.Profiles == nil {
+				conf.Profiles = make(map[string]profile)
+			}
+			conf.Profiles[name] = p
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("Failed to save profile: %s", err)
+		}
+
+		result += fmt.Sprintf("Saved profile %s for %s\n", w.Cyan(name), p.describe())
+	case "delete":
+		writer.SetCurrentStage("Deleting profile")
+		err := updateConfig(func(conf *config) error {
+			p, found := conf.Profiles[name]
+			if !found {
+				return fmt.Errorf("Profile '%s' not found", name)
+			}
+
+			err := removeProfileSecret(p, name)
+			if err != nil {
+				return err
+			}
+
+			delete(conf.Profiles, name)
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("Failed to delete profile: %s", err)
+		}
+
+		result += fmt.Sprintf("Deleted profile %s\n", w.Cyan(name))
+	default:
+		return "", fmt.Errorf("%s is not a valid action (must be list, show, set, or delete)", action)
+	}
+
+	return result, nil
+}
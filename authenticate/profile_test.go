@@ -0,0 +1,76 @@
+package authenticate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveProfileSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &encryptedStore{path: filepath.Join(dir, profileSecretsFileName), passphrase: "passphrase"}
+
+	steps := []struct {
+		name     string
+		password string
+		want     profileSecrets
+	}{
+		{"lab", "secret", profileSecrets{"lab": "secret"}},
+		{"prod", "other", profileSecrets{"lab": "secret", "prod": "other"}},
+		{"lab", "changed", profileSecrets{"lab": "changed", "prod": "other"}},
+		{"lab", "", profileSecrets{"prod": "other"}},
+		{"prod", "", profileSecrets{}},
+	}
+
+	for _, step := range steps {
+		err := saveProfileSecret(store, step.name, step.password)
+		if err != nil {
+			t.Fatalf("saveProfileSecret(%q, %q) returned error: %s", step.name, step.password, err)
+		}
+
+		secrets, err := readProfileSecrets(store)
+		if err != nil {
+			t.Fatalf("readProfileSecrets returned error: %s", err)
+		}
+		if len(secrets) != len(step.want) {
+			t.Errorf("after saving %q, secrets = %v, want %v", step.name, secrets, step.want)
+			continue
+		}
+		for name, password := range step.want {
+			if secrets[name] != password {
+				t.Errorf("after saving %q, secrets = %v, want %v", step.name, secrets, step.want)
+			}
+		}
+	}
+
+	// The store is removed once it holds no passwords
+	if _, err := os.Stat(store.path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after removing every password", store.path)
+	}
+}
+
+func TestReadProfileSecretsWrongPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, profileSecretsFileName)
+	err = saveProfileSecret(&encryptedStore{path: path, passphrase: "passphrase"}, "lab", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Passwords cannot be fetched again, so they must not be silently discarded
+	_, err = readProfileSecrets(&encryptedStore{path: path, passphrase: "wrong"})
+	if err == nil {
+		t.Error("readProfileSecrets succeeded with the wrong passphrase")
+	}
+}
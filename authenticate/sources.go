@@ -0,0 +1,224 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ibmjstart/swiftlygo/auth"
+)
+
+// credentialSource attempts to authenticate using one source of credentials.
+// It returns a nil Destination and no error if its credentials are unavailable.
+type credentialSource func(a *authenticator) (auth.Destination, error)
+
+// credentialSources lists the sources of credentials in the order they are tried.
+var credentialSources = []credentialSource{
+	(*authenticator).fromProfile,
+	(*authenticator).fromEnvironment,
+	(*authenticator).fromVcapServices,
+	(*authenticator).fromSavedCredentials,
+	(*authenticator).fromServiceKey,
+}
+
+// vcapService is a single service binding in the VCAP_SERVICES environment variable.
+type vcapService struct {
+	Name        string          `json:"name"`
+	Credentials json.RawMessage `json:"credentials"`
+}
+
+// fromProfile authenticates using the raw Swift credentials of the selected profile.
+func (a *authenticator) fromProfile() (auth.Destination, error) {
+	p := a.options.swiftProfile
+	if p == nil {
+		return nil, nil
+	}
+
+	a.creds = credentials{
+		AuthUrl:    p.AuthUrl,
+		DomainName: p.Domain,
+		Password:   p.Password,
+		Project:    p.Project,
+		ProjectID:  p.ProjectID,
+		Username:   p.Username,
+	}
+
+	return a.authenticateWithCreds()
+}
+
+// fromEnvironment authenticates using OpenStack environment variables, either
+// an existing token and storage url or a username and password.
+func (a *authenticator) fromEnvironment() (auth.Destination, error) {
+	token, storageUrl := os.Getenv("OS_AUTH_TOKEN"), os.Getenv("OS_STORAGE_URL")
+	if token != "" && storageUrl != "" {
+		a.writer.SetCurrentStage("Authenticating")
+		destination, err := auth.AuthenticateWithToken(token, storageUrl)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to authenticate with OS_AUTH_TOKEN and OS_STORAGE_URL: %s", err)
+		}
+
+		return destination, nil
+	}
+
+	// Swift TempAuth variables are used by the swift command line client
+	if os.Getenv("ST_AUTH") != "" && os.Getenv("ST_USER") != "" && os.Getenv("ST_KEY") != "" {
+		a.creds = credentials{
+			AuthUrl:  os.Getenv("ST_AUTH"),
+			Password: os.Getenv("ST_KEY"),
+			Username: os.Getenv("ST_USER"),
+		}
+		if a.options.AuthVersion == 0 {
+			a.options.AuthVersion = 1
+		}
+
+		return a.authenticateWithCreds()
+	}
+
+	authUrl, username, password := os.Getenv("OS_AUTH_URL"), os.Getenv("OS_USERNAME"), os.Getenv("OS_PASSWORD")
+	if authUrl == "" || username == "" || password == "" {
+		return nil, nil
+	}
+
+	if version := os.Getenv("OS_AUTH_VERSION"); version != "" && a.options.AuthVersion == 0 {
+		authVersion, err := parseAuthVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid OS_AUTH_VERSION: %s", err)
+		}
+		a.options.AuthVersion = authVersion
+	}
+
+	a.creds = credentials{
+		AuthUrl:       authUrl,
+		DomainName:    os.Getenv("OS_DOMAIN_NAME"),
+		Password:      password,
+		Project:       os.Getenv("OS_PROJECT_NAME"),
+		ProjectID:     os.Getenv("OS_PROJECT_ID"),
+		ProjectDomain: os.Getenv("OS_PROJECT_DOMAIN_NAME"),
+		Region:        os.Getenv("OS_REGION_NAME"),
+		Tenant:        os.Getenv("OS_TENANT_NAME"),
+		TenantID:      os.Getenv("OS_TENANT_ID"),
+		UserDomain:    os.Getenv("OS_USER_DOMAIN_NAME"),
+		Username:      username,
+	}
+
+	return a.authenticateWithCreds()
+}
+
+// fromVcapServices authenticates using the credentials of the target service's
+// binding in VCAP_SERVICES, as provided to running applications.
+func (a *authenticator) fromVcapServices() (auth.Destination, error) {
+	vcapServicesJSON := os.Getenv("VCAP_SERVICES")
+	if vcapServicesJSON == "" {
+		return nil, nil
+	}
+
+	var vcapServices map[string][]vcapService
+	err := json.Unmarshal([]byte(vcapServicesJSON), &vcapServices)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshall VCAP_SERVICES: %s", err)
+	}
+
+	for _, services := range vcapServices {
+		for _, service := range services {
+			if service.Name != a.targetService {
+				continue
+			}
+
+			err = a.extractCredsFromJSON(service.Credentials)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to extract credentials from VCAP_SERVICES: %s", err)
+			}
+
+			return a.authenticateWithCreds()
+		}
+	}
+
+	return nil, nil
+}
+
+// fromSavedCredentials authenticates using the token saved for the target service, if it is still valid.
+func (a *authenticator) fromSavedCredentials() (auth.Destination, error) {
+	// Saved credentials are only identifiable within a CF target
+	if loggedIn, err := a.cliConnection.IsLoggedIn(); err != nil || !loggedIn {
+		return nil, nil
+	}
+
+	// Determine which org and space the target service is expected in
+	err := a.getTarget()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get current target: %s", err)
+	}
+
+	// Check for and get saved service credentials
+	err = a.getSavedCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get saved credentials: %s", err)
+	}
+
+	// Determine if saved authentication was found for the target service and requested key and endpoint
+	_, isSaved := a.cache[a.cacheKey()]
+	if a.options.KeyName != "" && a.options.KeyName != a.authInfo.Key {
+		isSaved = false
+	}
+	if a.options.Region != "" && a.options.Region != a.authInfo.Region {
+		isSaved = false
+	}
+	if a.options.Interface != "" && a.options.Interface != a.authInfo.Interface {
+		isSaved = false
+	}
+
+	// Determine if the authentication token is still valid
+	isValid, err := a.authInfo.isValid()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSaved || !isValid {
+		return nil, nil
+	}
+
+	// Authenticate using the saved token, falling back to new credentials if it is rejected
+	a.writer.SetCurrentStage("Authenticating")
+	destination, err := auth.AuthenticateWithToken(a.authInfo.AuthToken, a.authInfo.StorageUrl)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Allow the token to be renewed if it expires or is rejected mid-operation
+	connection := destination.(*auth.SwiftDestination).SwiftConnection
+	connection.Expires = a.authInfo.Expires
+	connection.AuthUrl = a.authInfo.AuthUrl
+	connection.AuthVersion = a.authInfo.AuthVersion
+	connection.Auth = &renewingAuth{a: a}
+
+	return destination, nil
+}
+
+// fromServiceKey authenticates using the target service's service key and saves the resulting token.
+func (a *authenticator) fromServiceKey() (auth.Destination, error) {
+	err := a.getNewCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch a new set of credentials (Try running `cf login`): %s", err)
+	}
+
+	destination, err := a.authenticateWithCreds()
+	if err != nil {
+		return nil, err
+	}
+
+	a.authInfo.AuthToken = destination.(*auth.SwiftDestination).SwiftConnection.AuthToken
+	a.authInfo.StorageUrl = destination.(*auth.SwiftDestination).SwiftConnection.StorageUrl
+	a.authInfo.AuthUrl, a.authInfo.AuthVersion = a.authEndpoint()
+	a.authInfo.Region = destination.(*auth.SwiftDestination).SwiftConnection.Region
+	a.authInfo.Interface = string(destination.(*auth.SwiftDestination).SwiftConnection.EndpointType)
+	a.authInfo.ApiEndpoint = a.apiEndpoint
+	a.authInfo.Org = a.org
+	a.authInfo.Space = a.space
+	a.authInfo.Service = a.targetService
+	a.authInfo.Key = a.keyName
+	a.authInfo.Timestamp = time.Now()
+	a.authInfo.Expires = destination.(*auth.SwiftDestination).SwiftConnection.Expires
+
+	return destination, nil
+}
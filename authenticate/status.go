@@ -0,0 +1,212 @@
+package authenticate
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+)
+
+// hasFlag returns true if the given flag, with one or two leading dashes, is among the arguments.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "-"+name || arg == "--"+name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsStatusRequest returns true if the auth subcommand was asked for the status of saved credentials.
+func IsStatusRequest(args []string) bool {
+	return hasFlag(args, "status")
+}
+
+// expiry returns the time that a saved token stops being used.
+func (info authInfo) expiry() time.Time {
+	if !info.Expires.IsZero() {
+		return info.Expires
+	}
+
+	timeoutDuration, _ := time.ParseDuration(timeout)
+
+	return info.Timestamp.Add(timeoutDuration)
+}
+
+// sortedKeys returns the cache's keys in a stable order.
+func (cache authCache) sortedKeys() []string {
+	keys := make([]string, 0, len(cache))
+	for key := range cache {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// DisplayStatus prints the saved credentials, optionally only those of one service.
+func DisplayStatus(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, options Options, args []string) (string, error) {
+	writer.SetCurrentStage("Fetching saved credentials")
+
+	serviceName := ""
+	if len(args) > 2 && !strings.HasPrefix(args[2], "-") {
+		serviceName = args[2]
+	}
+
+	_, cache, err := loadCache()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get saved credentials: %s", err)
+	}
+
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+
+	found := false
+	for _, key := range cache.sortedKeys() {
+		info := cache[key]
+		if serviceName != "" && info.Service != serviceName {
+			continue
+		}
+		found = true
+
+		remaining := time.Until(info.expiry()).Round(time.Second)
+		validity := fmt.Sprintf("valid for %s", remaining)
+		if remaining <= 0 {
+			validity = w.Red("expired")
+		}
+
+		result += fmt.Sprintf("%s\n", w.Cyan(info.Service))
+		result += fmt.Sprintf("\t%s%s / org %s / space %s\n", w.White("target: "), info.ApiEndpoint, info.Org, info.Space)
+		if info.Key != "" {
+			result += fmt.Sprintf("\t%s%s\n", w.White("service key: "), info.Key)
+		}
+		result += fmt.Sprintf("\t%s%s\n", w.White("storage url: "), info.StorageUrl)
+		result += fmt.Sprintf("\t%s%s\n", w.White("age: "), time.Since(info.Timestamp).Round(time.Second))
+		result += fmt.Sprintf("\t%s%s (%s)\n", w.White("token: "), validity, info.expiry().Format(time.RFC3339))
+	}
+
+	if !found {
+		result += "No saved credentials\n"
+	}
+
+	return result, nil
+}
+
+// revokeToken invalidates a saved token with the Keystone server that issued it.
+func revokeToken(info authInfo) error {
+	if info.AuthUrl == "" {
+		return fmt.Errorf("No auth url was saved with the token")
+	}
+
+	var (
+		client  http.Client
+		request *http.Request
+		err     error
+	)
+
+	switch info.AuthVersion {
+	case 0:
+		// Tokens saved by earlier versions were always from an unversioned v3 auth url
+		request, err = http.NewRequest("DELETE", info.AuthUrl+"/v3/auth/tokens", nil)
+	case 3:
+		request, err = http.NewRequest("DELETE", info.AuthUrl+"/auth/tokens", nil)
+	case 2:
+		request, err = http.NewRequest("DELETE", info.AuthUrl+"/tokens/"+info.AuthToken, nil)
+	default:
+		return fmt.Errorf("v%d auth does not support revoking tokens", info.AuthVersion)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to create request: %s", err)
+	}
+	request.Header.Set("X-Auth-Token", info.AuthToken)
+	request.Header.Set("X-Subject-Token", info.AuthToken)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Failed to make request: %s", err)
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	// A token that is already invalid needs no revoking
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusUnauthorized {
+		return nil
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Failed to revoke token with status %s", response.Status)
+	}
+
+	return nil
+}
+
+// Logout revokes and removes the saved token of the given service, or of every service with -all.
+func Logout(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, options Options, args []string) (string, error) {
+	a := authenticator{
+		cliConnection: cliConnection,
+		writer:        writer,
+		options:       options,
+	}
+
+	all := hasFlag(args, "all")
+	if !all {
+		if len(args) < 3 || strings.HasPrefix(args[2], "-") {
+			return "", fmt.Errorf("Please provide a service_name or -all")
+		}
+		a.targetService = args[2]
+
+		err := a.getTarget()
+		if err != nil {
+			return "", fmt.Errorf("Failed to get current target: %s", err)
+		}
+	}
+
+	err := a.getSavedCredentials()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get saved credentials: %s", err)
+	}
+
+	keys := []string{a.cacheKey()}
+	if all {
+		keys = a.cache.sortedKeys()
+	}
+
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+
+	removed := 0
+	for _, key := range keys {
+		info, found := a.cache[key]
+		if !found {
+			continue
+		}
+
+		writer.SetCurrentStage("Revoking token for " + info.Service)
+		err = revokeToken(info)
+		if err != nil {
+			result += fmt.Sprintf("Could not revoke token for %s: %s\n", w.Cyan(info.Service), err)
+		}
+
+		result += fmt.Sprintf("Logged out of %s\n", w.Cyan(info.Service))
+		removed++
+	}
+
+	if removed == 0 {
+		return result + "No saved credentials to remove\n", nil
+	}
+
+	err = a.updateCache(func(cache authCache) {
+		for _, key := range keys {
+			delete(cache, key)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to remove saved credentials: %s", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,329 @@
+package authenticate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Names of the available credential stores, selected with the CredentialStore config value.
+const (
+	plaintextStoreName = "plaintext"
+	encryptedStoreName = "encrypted"
+	keyringStoreName   = "keyring"
+)
+
+// encryptedFileName is the name of the file in the user's .cf directory holding encrypted authentication info.
+const encryptedFileName = "os_creds.enc"
+
+// passphraseVariable is the environment variable holding the encrypted store's passphrase.
+const passphraseVariable = "CF_OS_PASSPHRASE"
+
+// keyringService and keyringUser identify the authentication info in the OS keyring.
+const (
+	keyringService = "cf-object-storage"
+	keyringUser    = "os_creds"
+)
+
+// profileSecretsFileName and keyringProfilesUser identify the passwords of profiles
+// in the encrypted store and the OS keyring.
+const (
+	profileSecretsFileName = "os_profiles.enc"
+	keyringProfilesUser    = "os_profiles"
+)
+
+// saltSize is the length, in bytes, of the salt used to derive the encrypted store's key.
+const saltSize = 16
+
+// secretStore loads and saves the serialized credential cache.
+type secretStore interface {
+	// load returns the saved contents, or nil if nothing has been saved
+	load() ([]byte, error)
+	save(contents []byte) error
+	remove() error
+}
+
+// corruptError reports saved contents that cannot be read, and should be replaced.
+type corruptError struct {
+	reason string
+}
+
+func (e *corruptError) Error() string {
+	return e.reason
+}
+
+// writeFileAtomic replaces a file by writing a temporary file in the same
+// directory and renaming it, so that readers never see a partial write.
+func writeFileAtomic(path string, contents []byte, perm os.FileMode) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file: %s", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write temporary file: %s", err)
+	}
+
+	err = os.Chmod(tempFile.Name(), perm)
+	if err != nil {
+		return fmt.Errorf("Failed to set permissions of temporary file: %s", err)
+	}
+
+	err = os.Rename(tempFile.Name(), path)
+	if err != nil {
+		return fmt.Errorf("Failed to replace %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// plaintextStore saves the credential cache as a plain JSON file.
+type plaintextStore struct {
+	path string
+}
+
+// encryptedStore saves the credential cache in a file encrypted with AES-GCM,
+// using a key derived from a passphrase.
+type encryptedStore struct {
+	path       string
+	passphrase string
+}
+
+// keyringStore saves the credential cache in the OS keyring, such as the
+// Secret Service on Linux, the Keychain on Mac, or the Credential Manager on Windows.
+type keyringStore struct {
+	user string
+}
+
+// newStore returns the named credential store, which keeps its contents at the
+// given path when encrypted or under the given user when in the OS keyring. The
+// plaintext store is returned as nil, as its contents are kept in files of their own.
+func newStore(storeName, encryptedPath, user string) (secretStore, error) {
+	switch storeName {
+	case "", plaintextStoreName:
+		return nil, nil
+	case encryptedStoreName:
+		passphrase := os.Getenv(passphraseVariable)
+		if passphrase == "" {
+			return nil, fmt.Errorf("%s must be set to use the %s credential store", passphraseVariable, encryptedStoreName)
+		}
+		return &encryptedStore{path: encryptedPath, passphrase: passphrase}, nil
+	case keyringStoreName:
+		return &keyringStore{user: user}, nil
+	default:
+		return nil, fmt.Errorf("Unknown credential store '%s' (must be %s, %s, or %s)", storeName,
+			plaintextStoreName, encryptedStoreName, keyringStoreName)
+	}
+}
+
+// getStore returns the credential store selected in the config, migrating any
+// credentials saved by the plaintext store to it.
+func getStore(credentialDir string) (secretStore, error) {
+	config, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := &plaintextStore{path: filepath.Join(credentialDir, cacheFileName)}
+
+	store, err := newStore(config.CredentialStore, filepath.Join(credentialDir, encryptedFileName), keyringUser)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return plaintext, nil
+	}
+
+	err = migrate(plaintext, store)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to migrate saved credentials to the %s store: %s", config.CredentialStore, err)
+	}
+
+	return store, nil
+}
+
+// migrate moves any contents of one store into another which has none, then removes them from the first.
+func migrate(from, to secretStore) error {
+	existing, err := to.load()
+	if _, isCorrupt := err.(*corruptError); isCorrupt {
+		existing, err = nil, nil
+	}
+	if err != nil || existing != nil {
+		return err
+	}
+
+	contents, err := from.load()
+	if err != nil || contents == nil {
+		return err
+	}
+
+	err = to.save(contents)
+	if err != nil {
+		return err
+	}
+
+	return from.remove()
+}
+
+func (s *plaintextStore) load() ([]byte, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", s.path, err)
+	}
+
+	if len(contents) == 0 {
+		return nil, nil
+	}
+
+	return contents, nil
+}
+
+func (s *plaintextStore) save(contents []byte) error {
+	err := writeFileAtomic(s.path, contents, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %s", s.path, err)
+	}
+
+	return nil
+}
+
+func (s *plaintextStore) remove() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove %s: %s", s.path, err)
+	}
+
+	return nil
+}
+
+// gcm creates the AES-GCM cipher for the given salt.
+func (s *encryptedStore) gcm(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(s.passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive key: %s", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create cipher: %s", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// load decrypts the file, which holds the salt, nonce, and ciphertext in that order.
+func (s *encryptedStore) load() ([]byte, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", s.path, err)
+	}
+
+	if len(contents) < saltSize {
+		return nil, &corruptError{fmt.Sprintf("%s is too short to contain encrypted credentials", s.path)}
+	}
+
+	gcm, err := s.gcm(contents[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := contents[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, &corruptError{fmt.Sprintf("%s is too short to contain encrypted credentials", s.path)}
+	}
+
+	// Contents that cannot be decrypted, whether corrupt or encrypted with a
+	// different passphrase, only hold tokens and so are replaced
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, &corruptError{fmt.Sprintf("Failed to decrypt %s: %s", s.path, err)}
+	}
+
+	return plaintext, nil
+}
+
+func (s *encryptedStore) save(contents []byte) error {
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return fmt.Errorf("Failed to generate salt: %s", err)
+	}
+
+	gcm, err := s.gcm(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return fmt.Errorf("Failed to generate nonce: %s", err)
+	}
+
+	encrypted := append(salt, gcm.Seal(nonce, nonce, contents, nil)...)
+
+	err = writeFileAtomic(s.path, encrypted, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %s", s.path, err)
+	}
+
+	return nil
+}
+
+func (s *encryptedStore) remove() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove %s: %s", s.path, err)
+	}
+
+	return nil
+}
+
+func (s *keyringStore) load() ([]byte, error) {
+	contents, err := keyring.Get(keyringService, s.user)
+	if err == keyring.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read from keyring: %s", err)
+	}
+
+	return []byte(contents), nil
+}
+
+func (s *keyringStore) save(contents []byte) error {
+	err := keyring.Set(keyringService, s.user, string(contents))
+	if err != nil {
+		return fmt.Errorf("Failed to write to keyring: %s", err)
+	}
+
+	return nil
+}
+
+func (s *keyringStore) remove() error {
+	err := keyring.Delete(keyringService, s.user)
+	if err != nil && err != keyring.ErrNotFound {
+		return fmt.Errorf("Failed to remove from keyring: %s", err)
+	}
+
+	return nil
+}
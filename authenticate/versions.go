@@ -0,0 +1,123 @@
+package authenticate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// versionPaths are the paths appended to an unversioned auth url for each auth version.
+var versionPaths = map[int]string{
+	1: "/auth/v1.0",
+	2: "/v2.0",
+	3: "/v3",
+}
+
+// parseAuthVersion reads an auth version such as 1, 2, 2.0, v3, or 3.0.
+func parseAuthVersion(value string) (int, error) {
+	switch strings.TrimPrefix(strings.ToLower(value), "v") {
+	case "1", "1.0":
+		return 1, nil
+	case "2", "2.0":
+		return 2, nil
+	case "3", "3.0":
+		return 3, nil
+	}
+
+	return 0, fmt.Errorf("Auth version must be 1, 2, or 3")
+}
+
+// detectAuthVersion returns the auth version included in an auth url, or 0 if there is none.
+func detectAuthVersion(authUrl string) int {
+	trimmed := strings.TrimSuffix(authUrl, "/")
+	switch {
+	case strings.HasSuffix(trimmed, "/v3"):
+		return 3
+	case strings.HasSuffix(trimmed, "/v2.0"), strings.HasSuffix(trimmed, "/v2"):
+		return 2
+	case strings.HasSuffix(trimmed, "/v1.0"), strings.HasSuffix(trimmed, "/v1"):
+		return 1
+	}
+
+	return 0
+}
+
+// authEndpoint returns the auth url and version to authenticate with. The
+// version is taken from the auth-version flag, then the auth url, and
+// defaults to Keystone v3.
+func (a *authenticator) authEndpoint() (string, int) {
+	authUrl := strings.TrimSuffix(a.creds.AuthUrl, "/")
+	urlVersion := detectAuthVersion(authUrl)
+
+	version := a.options.AuthVersion
+	if version == 0 {
+		version = urlVersion
+	}
+	if version == 0 {
+		version = 3
+	}
+
+	if urlVersion == 0 {
+		authUrl += versionPaths[version]
+	}
+
+	return authUrl, version
+}
+
+// authenticateWithCreds authenticates with Object Storage using the parsed credentials.
+func (a *authenticator) authenticateWithCreds() (auth.Destination, error) {
+	a.writer.SetCurrentStage("Authenticating")
+
+	authUrl, version := a.authEndpoint()
+
+	connection := &swift.Connection{
+		UserName:    a.creds.Username,
+		UserId:      a.creds.UserID,
+		ApiKey:      a.creds.Password,
+		AuthUrl:     authUrl,
+		AuthVersion: version,
+		Region:      firstNonEmpty(a.options.Region, a.creds.Region),
+	}
+
+	if a.options.Interface != "" {
+		connection.EndpointType = swift.EndpointType(a.options.Interface)
+	}
+
+	switch version {
+	case 2:
+		// Keystone v2 scopes tokens to a tenant, which newer credentials call a project
+		connection.UserId = ""
+		connection.Tenant = firstNonEmpty(a.creds.Tenant, a.creds.Project)
+		connection.TenantId = firstNonEmpty(a.creds.TenantID, a.creds.ProjectID)
+	case 3:
+		connection.Domain = firstNonEmpty(a.creds.UserDomain, a.creds.DomainName)
+		connection.DomainId = a.creds.DomainID
+		connection.Tenant = firstNonEmpty(a.creds.Project, a.creds.Tenant)
+		connection.TenantId = firstNonEmpty(a.creds.ProjectID, a.creds.TenantID)
+		connection.TenantDomain = a.creds.ProjectDomain
+	}
+
+	err := connection.Authenticate()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to authenticate with v%d auth at %s: %s", version, authUrl, err)
+	}
+
+	if connection.StorageUrl == "" {
+		return nil, fmt.Errorf("No object-store endpoint found for region '%s' and interface '%s'", connection.Region, connection.EndpointType)
+	}
+
+	return &auth.SwiftDestination{SwiftConnection: connection}, nil
+}
+
+// firstNonEmpty returns the first of the given values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
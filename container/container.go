@@ -1,8 +1,17 @@
 package container
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	w "github.com/ibmjstart/cf-object-storage/writer"
 	"github.com/ibmjstart/swiftlygo/auth"
@@ -15,18 +24,187 @@ var shortHeaders = map[string]string{
 	"-rm-gr": "X-Remove-Container-Read:1",
 }
 
-// ShowContainers displays the containers in a given Object Storage service.
+// listingPageSize is the largest number of containers requested from the account listing at once.
+const listingPageSize = 10000
+
+// containerListing is a container's entry in the account listing.
+type containerListing struct {
+	Name         string `json:"name"`
+	Count        int64  `json:"count"`
+	Bytes        int64  `json:"bytes"`
+	LastModified string `json:"last_modified"`
+}
+
+// listFlagVal holds the flag values of the containers subcommand.
+type listFlagVal struct {
+	sortFlag   string
+	prefixFlag string
+	markerFlag string
+	limitFlag  int
+}
+
+// sortFuncs compare two containers for each supported sort order. Names are
+// sorted in ascending order, and the rest largest or newest first.
+var sortFuncs = map[string]func(a, b containerListing) bool{
+	"name":     func(a, b containerListing) bool { return a.Name < b.Name },
+	"count":    func(a, b containerListing) bool { return a.Count > b.Count },
+	"bytes":    func(a, b containerListing) bool { return a.Bytes > b.Bytes },
+	"modified": func(a, b containerListing) bool { return a.LastModified > b.LastModified },
+}
+
+// parseListFlags reads the flags provided to the containers subcommand.
+func parseListFlags(args []string) (*listFlagVal, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	sortBy := flagSet.String("sort", "name", "Sort by name, count, bytes, or modified")
+	prefix := flagSet.String("prefix", "", "Only show containers beginning with this prefix")
+	marker := flagSet.String("marker", "", "Only show containers after this name")
+	limit := flagSet.Int("limit", 0, "Show at most this many containers")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	if _, found := sortFuncs[*sortBy]; !found {
+		return nil, fmt.Errorf("Cannot sort by %s (must be name, count, bytes, or modified)", *sortBy)
+	}
+	if *limit < 0 {
+		return nil, fmt.Errorf("Limit must not be negative")
+	}
+
+	flagVals := listFlagVal{
+		sortFlag:   string(*sortBy),
+		prefixFlag: string(*prefix),
+		markerFlag: string(*marker),
+		limitFlag:  int(*limit),
+	}
+
+	return &flagVals, nil
+}
+
+// listContainers fetches a page of the account listing. The listing is requested
+// directly because the swift package omits each container's last-modified time.
+func listContainers(connection *swift.Connection, prefix, marker string, limit int) ([]containerListing, error) {
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("limit", strconv.Itoa(limit))
+	if prefix != "" {
+		params.Set("prefix", prefix)
+	}
+	if marker != "" {
+		params.Set("marker", marker)
+	}
+
+	response, _, err := connection.Call(connection.StorageUrl, swift.RequestOpts{
+		Operation:  "GET",
+		Parameters: params,
+		OnReAuth: func() (string, error) {
+			return connection.StorageUrl, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	containers := make([]containerListing, 0)
+	if response.StatusCode == http.StatusNoContent {
+		return containers, nil
+	}
+
+	err = json.NewDecoder(response.Body).Decode(&containers)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshall container listing: %s", err)
+	}
+
+	return containers, nil
+}
+
+// formatModified shortens a listing's last-modified time to the second.
+func formatModified(lastModified string) string {
+	modified, err := time.Parse("2006-01-02T15:04:05.999999", lastModified)
+	if err != nil {
+		return "-"
+	}
+
+	return modified.Format("2006-01-02 15:04:05")
+}
+
+// ShowContainers displays a table of the containers in a given Object Storage service.
 func ShowContainers(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
 	writer.SetCurrentStage("Displaying containers")
 
 	serviceName := args[2]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
 
-	containers, err := dest.(*auth.SwiftDestination).SwiftConnection.ContainerNamesAll(nil)
+	flagVals, err := parseListFlags(args[3:])
 	if err != nil {
-		return "", fmt.Errorf("Failed to get containers: %s", err)
+		return "", err
+	}
+
+	// Page through the listing until the limit, or the end of the listing, is reached
+	containers := make([]containerListing, 0)
+	marker := flagVals.markerFlag
+	for {
+		pageSize := listingPageSize
+		if flagVals.limitFlag > 0 && flagVals.limitFlag-len(containers) < pageSize {
+			pageSize = flagVals.limitFlag - len(containers)
+		}
+
+		page, err := listContainers(connection, flagVals.prefixFlag, marker, pageSize)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get containers: %s", err)
+		}
+
+		containers = append(containers, page...)
+		if len(page) < pageSize || len(containers) == flagVals.limitFlag {
+			break
+		}
+
+		marker = page[len(page)-1].Name
+		writer.SetCurrentStage(fmt.Sprintf("Displaying containers (%d found)", len(containers)))
+	}
+
+	// Paging continues from the last container in name order, whichever way the table is sorted
+	lastName := ""
+	if len(containers) > 0 {
+		lastName = containers[len(containers)-1].Name
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return sortFuncs[flagVals.sortFlag](containers[i], containers[j])
+	})
+
+	result := fmt.Sprintf("\r%s%s\n\nContainers in OS %s:\n", w.ClearLine, w.Green("OK"), serviceName)
+	if len(containers) == 0 {
+		return result + "No containers found\n", nil
+	}
+
+	var (
+		table       bytes.Buffer
+		totalCount  int64
+		totalBytes  int64
+		tableWriter = tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	)
+
+	fmt.Fprintln(tableWriter, "NAME\tOBJECTS\tSIZE\tLAST MODIFIED")
+	for _, container := range containers {
+		fmt.Fprintf(tableWriter, "%s\t%d\t%s\t%s\n", container.Name, container.Count, w.FormatBytes(container.Bytes), formatModified(container.LastModified))
+		totalCount += container.Count
+		totalBytes += container.Bytes
+	}
+	tableWriter.Flush()
+
+	result += table.String()
+	result += fmt.Sprintf("\n%d containers, %d objects, %s\n", len(containers), totalCount, w.FormatBytes(totalBytes))
+
+	// Without a limit the whole listing is shown, otherwise there may be more to page through
+	if flagVals.limitFlag > 0 && len(containers) == flagVals.limitFlag {
+		result += fmt.Sprintf("More containers may follow, rerun with --marker %s to list them\n", lastName)
 	}
 
-	return fmt.Sprintf("\r%s%s\n\nContainers in OS %s: %v\n", w.ClearLine, w.Green("OK"), serviceName, containers), nil
+	return result, nil
 }
 
 // GetContainerInfo displays metadata for a given container.
@@ -0,0 +1,579 @@
+package directory
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibmjstart/cf-object-storage/object"
+	"github.com/ibmjstart/cf-object-storage/slo"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// putFlagVal holds the flag values of the put-dir subcommand.
+type putFlagVal struct {
+	prefixFlag     string
+	numThreadsFlag int
+}
+
+// mtimeHeader is the object metadata holding the modification time of the uploaded file,
+// as used by the OpenStack swift client.
+const mtimeHeader = "X-Object-Meta-Mtime"
+
+// localFile is a file in the local directory and the object it corresponds to.
+type localFile struct {
+	path       string
+	objectName string
+	size       int64
+	modTime    time.Time
+}
+
+// transferResult is the outcome of transferring a single file.
+type transferResult struct {
+	file    localFile
+	skipped bool
+	err     error
+}
+
+// parsePutFlags reads the flags provided to the put-dir subcommand.
+func parsePutFlags(args []string) (*putFlagVal, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	prefix := flagSet.String("p", "", "Prefix added to the name of every object")
+	threads := flagSet.Int("t", runtime.NumCPU(), "Number of files to upload at once")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	if *threads < 1 {
+		return nil, fmt.Errorf("Number of threads must be at least 1")
+	}
+
+	flagVals := putFlagVal{
+		prefixFlag:     string(*prefix),
+		numThreadsFlag: int(*threads),
+	}
+
+	return &flagVals, nil
+}
+
+// parseThreadsFlag reads the number of threads from the flags provided to the get-dir subcommand.
+func parseThreadsFlag(args []string) (int, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	threads := flagSet.Int("t", runtime.NumCPU(), "Number of files to download at once")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	if *threads < 1 {
+		return 0, fmt.Errorf("Number of threads must be at least 1")
+	}
+
+	return *threads, nil
+}
+
+// formatMtime formats a modification time as the seconds since the epoch.
+func formatMtime(modTime time.Time) string {
+	return fmt.Sprintf("%d.%06d", modTime.Unix(), modTime.Nanosecond()/1000)
+}
+
+// parseMtime reads the modification time saved with an object, if it has one.
+func parseMtime(headers swift.Headers) (time.Time, bool) {
+	seconds, err := strconv.ParseFloat(headers[mtimeHeader], 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	whole := int64(seconds)
+
+	return time.Unix(whole, int64((seconds-float64(whole))*1e9)), true
+}
+
+// objectName maps a path relative to the local directory to an object name under the prefix.
+func objectName(prefix, relativePath string) string {
+	return path.Join(prefix, filepath.ToSlash(relativePath))
+}
+
+// findLocalFiles returns every regular file beneath the local directory.
+func findLocalFiles(localDir, prefix string) ([]localFile, error) {
+	files := make([]localFile, 0)
+
+	err := filepath.Walk(localDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(localDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, localFile{
+			path:       filePath,
+			objectName: objectName(prefix, relativePath),
+			size:       info.Size(),
+			modTime:    info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory %s: %s", localDir, err)
+	}
+
+	return files, nil
+}
+
+// pseudoDirectory returns the prefix as a pseudo-directory, so that it only
+// matches the objects beneath it rather than every name beginning with it.
+func pseudoDirectory(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return prefix
+}
+
+// localPath maps an object name beneath the prefix to a path in the local directory,
+// refusing names that would lead outside of it. Only the pseudo-directories of the
+// prefix are removed from the name, so that a name is never cut part way through.
+func localPath(localDir, prefix, name string) (string, error) {
+	prefixDir := prefix[:strings.LastIndex(prefix, "/")+1]
+	relativePath := strings.TrimPrefix(name, prefixDir)
+
+	filePath := filepath.Join(localDir, filepath.FromSlash(relativePath))
+	relativeToDir, err := filepath.Rel(localDir, filePath)
+	if err != nil || relativeToDir == ".." || strings.HasPrefix(relativeToDir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Object name %s leads outside of %s", name, localDir)
+	}
+
+	return filePath, nil
+}
+
+// findRemoteObjects returns the objects beneath the prefix, keyed by name.
+func findRemoteObjects(connection *swift.Connection, container, prefix string) (map[string]swift.Object, error) {
+	objects, err := connection.ObjectsAll(container, &swift.ObjectsOpts{Prefix: prefix})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get objects in container %s: %s", container, err)
+	}
+
+	remote := make(map[string]swift.Object, len(objects))
+	for _, remoteObject := range objects {
+		remote[remoteObject.Name] = remoteObject
+	}
+
+	return remote, nil
+}
+
+// uploadFile streams a file to an object, skipping it if the object already has
+// the same contents. Object Storage verifies the upload against the file's MD5.
+func uploadFile(connection *swift.Connection, progress *w.TransferProgress, container string, file localFile,
+	remote map[string]swift.Object) transferResult {
+	hash := ""
+
+	// Files are only hashed if an object of the same size could hold the same contents
+	remoteObject, exists := remote[file.objectName]
+	if exists && remoteObject.Bytes == file.size {
+		var err error
+		hash, err = object.HashFile(file.path)
+		if err != nil {
+			return transferResult{file: file, err: err}
+		}
+
+		if hash == remoteObject.Hash {
+			progress.Add(file.size)
+			return transferResult{file: file, skipped: true}
+		}
+	}
+
+	source, err := os.Open(file.path)
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to open %s: %s", file.path, err)}
+	}
+	defer source.Close()
+
+	headers := swift.Headers{mtimeHeader: formatMtime(file.modTime)}
+	objectCreator, err := connection.ObjectCreate(container, file.objectName, true, hash, "", headers)
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to create object: %s", err)}
+	}
+
+	_, err = io.Copy(objectCreator, progress.Reader(source))
+	if err != nil {
+		objectCreator.CloseWithError(err)
+		return transferResult{file: file, err: fmt.Errorf("Failed to write object: %s", err)}
+	}
+
+	err = objectCreator.Close()
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to close object writer: %s", err)}
+	}
+
+	return transferResult{file: file}
+}
+
+// runParallel runs a task for each index up to count using a pool of workers.
+func runParallel(count, numThreads int, task func(i int)) {
+	jobs := make(chan int)
+
+	var workers sync.WaitGroup
+	for t := 0; t < numThreads; t++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				task(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	workers.Wait()
+}
+
+// transferAll runs the transfer function on every file using a pool of workers.
+func transferAll(files []localFile, numThreads int, transfer func(file localFile) transferResult) []transferResult {
+	results := make([]transferResult, len(files))
+	runParallel(len(files), numThreads, func(i int) {
+		results[i] = transfer(files[i])
+	})
+
+	return results
+}
+
+// summarize reports the number of files transferred, skipped, and failed, returning
+// an error listing the failures if there were any.
+func summarize(results []transferResult, verb string) (string, error) {
+	var (
+		transferred int
+		skipped     int
+		bytes       int64
+		failures    string
+	)
+
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failures += fmt.Sprintf("\t%s: %s\n", result.file.path, result.err)
+		case result.skipped:
+			skipped++
+		default:
+			transferred++
+			bytes += result.file.size
+		}
+	}
+
+	summary := fmt.Sprintf("%s %d files (%s), skipped %d unchanged files", verb, transferred, w.FormatBytes(bytes), skipped)
+	if failures != "" {
+		return "", fmt.Errorf("%s, failed %d files:\n%s", summary, len(results)-transferred-skipped, failures)
+	}
+
+	return summary + "\n", nil
+}
+
+// PutDir uploads every file beneath a local directory to a container, skipping files whose objects are unchanged.
+func PutDir(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Finding files to upload")
+
+	container := args[3]
+	localDir := args[4]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	flagVals, err := parsePutFlags(args[5:])
+	if err != nil {
+		return "", err
+	}
+
+	files, err := findLocalFiles(localDir, flagVals.prefixFlag)
+	if err != nil {
+		return "", err
+	}
+
+	writer.SetCurrentStage("Comparing with existing objects")
+	remote, err := findRemoteObjects(connection, container, flagVals.prefixFlag)
+	if err != nil {
+		return "", err
+	}
+
+	// Files too large for a single object are uploaded as SLOs after the rest
+	var (
+		smallFiles = make([]localFile, 0, len(files))
+		largeFiles = make([]localFile, 0)
+		totalSize  int64
+	)
+	for _, file := range files {
+		if uint(file.size) > object.MaxObjectSize {
+			largeFiles = append(largeFiles, file)
+		} else {
+			smallFiles = append(smallFiles, file)
+		}
+		totalSize += file.size
+	}
+
+	writer.SetCurrentStage(fmt.Sprintf("Uploading %d files", len(files)))
+	progress := w.NewTransferProgress(totalSize)
+	writer.SetStatus(progress)
+
+	results := transferAll(smallFiles, flagVals.numThreadsFlag, func(file localFile) transferResult {
+		return uploadFile(connection, progress, container, file, remote)
+	})
+
+	for _, file := range largeFiles {
+		writer.SetCurrentStage("Uploading " + file.objectName + " as an SLO")
+		results = append(results, uploadLargeFile(dest, writer, progress, container, file, remote, flagVals.numThreadsFlag))
+	}
+
+	summary, err := summarize(results, "Uploaded")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("\r%s%s\n%s\n%s", w.ClearLine, w.Green("OK"), w.ClearLine, summary), nil
+}
+
+// isUploaded returns true if an existing SLO already holds the file's contents,
+// comparing the MD5 of each part of the file with the segments in its manifest.
+func isUploaded(connection *swift.Connection, container string, file localFile, remote map[string]swift.Object) (bool, error) {
+	if _, exists := remote[file.objectName]; !exists {
+		return false, nil
+	}
+
+	segments, err := slo.GetSegments(connection, container, file.objectName)
+	if err != nil || segments == nil {
+		return false, err
+	}
+
+	var (
+		manifestSize   int64
+		manifestHashes string
+	)
+	for _, segment := range segments {
+		manifestSize += segment.Bytes
+		manifestHashes += segment.Hash
+	}
+	if manifestSize != file.size {
+		return false, nil
+	}
+
+	hash, err := object.HashFileSegments(file.path, segments)
+	if err != nil {
+		return false, err
+	}
+	manifestHash := md5.Sum([]byte(manifestHashes))
+
+	return hash == hex.EncodeToString(manifestHash[:]), nil
+}
+
+// uploadLargeFile uploads a file as an SLO, skipping it if the existing SLO already
+// has the same contents and otherwise only uploading the segments that are missing
+// or changed. The SLO uploader shows its own progress while it runs, after which
+// the file is added to the overall progress.
+func uploadLargeFile(dest auth.Destination, writer *w.ConsoleWriter, progress *w.TransferProgress, container string,
+	file localFile, remote map[string]swift.Object, numThreads int) transferResult {
+	defer writer.SetStatus(progress)
+
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+	uploaded, err := isUploaded(connection, container, file, remote)
+	if err != nil {
+		return transferResult{file: file, err: err}
+	}
+	if uploaded {
+		progress.Add(file.size)
+		return transferResult{file: file, skipped: true}
+	}
+
+	source, err := os.Open(file.path)
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to open %s: %s", file.path, err)}
+	}
+	defer source.Close()
+
+	err = slo.UploadFile(dest, writer, container, file.objectName, source, slo.ChunkSizeFor(file.size, int64(object.MaxObjectSize)),
+		uint(numThreads), true, ioutil.Discard)
+	if err != nil {
+		return transferResult{file: file, err: err}
+	}
+	progress.Add(file.size)
+
+	return transferResult{file: file}
+}
+
+// isDownloaded returns true if the local file already holds the object's
+// contents. DLOs have no ETag to compare with, so are always downloaded.
+func isDownloaded(connection *swift.Connection, container string, file localFile, remoteHash string) (bool, error) {
+	info, err := os.Stat(file.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("Failed to get info of %s: %s", file.path, err)
+	}
+
+	if info.Size() != file.size {
+		return false, nil
+	}
+
+	hash, err := object.HashFile(file.path)
+	if err != nil || hash == remoteHash {
+		return err == nil, err
+	}
+
+	segments, err := slo.GetSegments(connection, container, file.objectName)
+	if err != nil || segments == nil {
+		return false, err
+	}
+
+	sloHash, err := object.HashFileSegments(file.path, segments)
+	if err != nil {
+		return false, err
+	}
+
+	return sloHash == remoteHash, nil
+}
+
+// removeStalePartials removes the partial files of interrupted downloads of other
+// versions of the files' objects, whose current ETags are given by object name.
+func removeStalePartials(files []localFile, hashes map[string]string) error {
+	partPaths := make(map[string]string, len(files))
+	for _, file := range files {
+		partPaths[file.path] = object.PartialPath(file.path, hashes[file.objectName])
+	}
+
+	return object.RemoveStalePartials(partPaths)
+}
+
+// downloadFile downloads an object, or the concatenated segments of a large
+// object, to a local file unless the file already holds the same contents. The
+// file is only replaced once the download is complete and verified.
+func downloadFile(connection *swift.Connection, progress *w.TransferProgress, container string, file localFile,
+	remoteHash string) transferResult {
+	downloaded, err := isDownloaded(connection, container, file, remoteHash)
+	if err != nil {
+		return transferResult{file: file, err: err}
+	}
+	if downloaded {
+		progress.Add(file.size)
+		return transferResult{file: file, skipped: true}
+	}
+
+	err = os.MkdirAll(filepath.Dir(file.path), 0755)
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to create directory: %s", err)}
+	}
+
+	objectInfo, headers, err := connection.Object(container, file.objectName)
+	if err != nil {
+		return transferResult{file: file, err: fmt.Errorf("Failed to get object: %s", err)}
+	}
+
+	err = object.DownloadFile(connection, progress, container, file.objectName, file.path, objectInfo.Bytes, headers)
+	if err != nil {
+		return transferResult{file: file, err: err}
+	}
+
+	// Preserve the modification time of files that were uploaded with one
+	if modTime, found := parseMtime(headers); found {
+		os.Chtimes(file.path, modTime, modTime)
+	}
+
+	return transferResult{file: file}
+}
+
+// GetDir downloads every object in a container, or beneath a prefix, to a local
+// directory, skipping objects whose local files are unchanged.
+func GetDir(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Finding objects to download")
+
+	container := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	// The prefix is optional, so the local directory is the last argument before any flags
+	prefix, localDir, flags := "", args[4], args[5:]
+	if len(args) > 5 && !strings.HasPrefix(args[5], "-") {
+		prefix, localDir, flags = args[4], args[5], args[6:]
+	}
+
+	numThreads, err := parseThreadsFlag(flags)
+	if err != nil {
+		return "", err
+	}
+	prefix = pseudoDirectory(prefix)
+
+	objects, err := connection.ObjectsAll(container, &swift.ObjectsOpts{Prefix: prefix})
+	if err != nil {
+		return "", fmt.Errorf("Failed to get objects in container %s: %s", container, err)
+	}
+
+	var (
+		files     = make([]localFile, 0, len(objects))
+		hashes    = make(map[string]string, len(objects))
+		totalSize int64
+	)
+	for _, remoteObject := range objects {
+		filePath, err := localPath(localDir, prefix, remoteObject.Name)
+		if err != nil {
+			return "", err
+		}
+
+		// Pseudo-directory marker objects become local directories
+		if strings.HasSuffix(remoteObject.Name, "/") || remoteObject.ContentType == "application/directory" {
+			err = os.MkdirAll(filePath, 0755)
+			if err != nil {
+				return "", fmt.Errorf("Failed to create directory %s: %s", filePath, err)
+			}
+			continue
+		}
+
+		files = append(files, localFile{
+			path:       filePath,
+			objectName: remoteObject.Name,
+			size:       remoteObject.Bytes,
+		})
+		hashes[remoteObject.Name] = remoteObject.Hash
+		totalSize += remoteObject.Bytes
+	}
+
+	err = removeStalePartials(files, hashes)
+	if err != nil {
+		return "", err
+	}
+
+	writer.SetCurrentStage(fmt.Sprintf("Downloading %d objects", len(files)))
+	progress := w.NewTransferProgress(totalSize)
+	writer.SetStatus(progress)
+
+	results := transferAll(files, numThreads, func(file localFile) transferResult {
+		return downloadFile(connection, progress, container, file, hashes[file.objectName])
+	})
+
+	summary, err := summarize(results, "Downloaded")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("\r%s%s\n%s\n%s", w.ClearLine, w.Green("OK"), w.ClearLine, summary), nil
+}
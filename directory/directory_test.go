@@ -0,0 +1,65 @@
+package directory
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPseudoDirectory(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", ""},
+		{"photos", "photos/"},
+		{"photos/", "photos/"},
+		{"photos/2017", "photos/2017/"},
+	}
+
+	for _, test := range tests {
+		if got := pseudoDirectory(test.prefix); got != test.want {
+			t.Errorf("pseudoDirectory(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	localDir := filepath.FromSlash("/out")
+
+	tests := []struct {
+		prefix string
+		name   string
+		want   string
+	}{
+		{"", "a.jpg", "/out/a.jpg"},
+		{"", "photos/a.jpg", "/out/photos/a.jpg"},
+		{"photos/", "photos/a.jpg", "/out/a.jpg"},
+		{"photos/", "photos/2017/a.jpg", "/out/2017/a.jpg"},
+		{"photos/", "photos/", "/out"},
+		// A prefix that is not a pseudo-directory never cuts a name part way through
+		{"photos", "photos2/a.jpg", "/out/photos2/a.jpg"},
+		{"photos", "photos.json", "/out/photos.json"},
+		{"photos/2017", "photos/2017-01/a.jpg", "/out/2017-01/a.jpg"},
+	}
+
+	for _, test := range tests {
+		got, err := localPath(localDir, test.prefix, test.name)
+		if err != nil {
+			t.Errorf("localPath(%q, %q) returned error: %s", test.prefix, test.name, err)
+			continue
+		}
+		if want := filepath.FromSlash(test.want); got != want {
+			t.Errorf("localPath(%q, %q) = %q, want %q", test.prefix, test.name, got, want)
+		}
+	}
+}
+
+func TestLocalPathOutsideDirectory(t *testing.T) {
+	names := []string{"../a.jpg", "photos/../../a.jpg", "photos/../../out2/a.jpg"}
+
+	for _, name := range names {
+		if _, err := localPath(filepath.FromSlash("/out"), "", name); err == nil {
+			t.Errorf("localPath(%q) succeeded, want error", name)
+		}
+	}
+}
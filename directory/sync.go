@@ -0,0 +1,485 @@
+package directory
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ibmjstart/cf-object-storage/object"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// Directions that sync can converge in.
+const (
+	directionUp   = "up"
+	directionDown = "down"
+	directionBoth = "both"
+)
+
+// Actions that sync takes to converge a file and its object.
+const (
+	actionUpload       = "upload"
+	actionDownload     = "download"
+	actionDeleteRemote = "delete-remote"
+	actionDeleteLocal  = "delete-local"
+)
+
+// globList is a repeatable flag holding glob patterns.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(pattern string) error {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return err
+	}
+
+	*g = append(*g, pattern)
+
+	return nil
+}
+
+// syncFlagVal holds the flag values of the sync subcommand.
+type syncFlagVal struct {
+	prefixFlag     string
+	numThreadsFlag int
+	directionFlag  string
+	deleteFlag     bool
+	dryRunFlag     bool
+	includeFlag    globList
+	excludeFlag    globList
+	reportFlag     string
+}
+
+// syncEntry pairs a local file and an object with the same relative name, either of which may be missing.
+type syncEntry struct {
+	relativeName string
+	file         localFile
+	remote       swift.Object
+	hasLocal     bool
+	hasRemote    bool
+}
+
+// change is an action taken by sync, as listed in its report.
+type change struct {
+	Action string `json:"action"`
+	Object string `json:"object"`
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+
+	entry syncEntry
+}
+
+// syncReport is the machine-readable report of the changes made by sync.
+type syncReport struct {
+	Container string   `json:"container"`
+	Prefix    string   `json:"prefix"`
+	LocalDir  string   `json:"local_dir"`
+	Direction string   `json:"direction"`
+	DryRun    bool     `json:"dry_run"`
+	Unchanged int      `json:"unchanged"`
+	Changes   []change `json:"changes"`
+}
+
+// parseSyncFlags reads the flags provided to the sync subcommand.
+func parseSyncFlags(args []string) (*syncFlagVal, error) {
+	var flagVals syncFlagVal
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	flagSet.StringVar(&flagVals.prefixFlag, "p", "", "Pseudo-directory in the container to sync with")
+	flagSet.IntVar(&flagVals.numThreadsFlag, "t", runtime.NumCPU(), "Number of files to transfer at once")
+	flagSet.StringVar(&flagVals.directionFlag, "direction", directionUp, "up, down, or both")
+	flagSet.BoolVar(&flagVals.deleteFlag, "delete", false, "Delete files or objects missing from the source")
+	flagSet.BoolVar(&flagVals.dryRunFlag, "dry-run", false, "Only report the changes that would be made")
+	flagSet.Var(&flagVals.includeFlag, "include", "Only sync files matching this glob")
+	flagSet.Var(&flagVals.excludeFlag, "exclude", "Do not sync files matching this glob")
+	flagSet.StringVar(&flagVals.reportFlag, "report", "", "File to write a JSON report of the changes to")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	switch flagVals.directionFlag {
+	case directionUp, directionDown:
+	case directionBoth:
+		if flagVals.deleteFlag {
+			return nil, fmt.Errorf("-delete cannot be used when syncing in both directions")
+		}
+	default:
+		return nil, fmt.Errorf("Direction must be up, down, or both")
+	}
+
+	if flagVals.numThreadsFlag < 1 {
+		return nil, fmt.Errorf("Number of threads must be at least 1")
+	}
+
+	// Sync within a pseudo-directory, rather than with every object beginning with the prefix
+	flagVals.prefixFlag = pseudoDirectory(flagVals.prefixFlag)
+
+	return &flagVals, nil
+}
+
+// matchesAny returns true if the relative name, or its base name, matches any of the globs.
+func matchesAny(globs globList, relativeName string) bool {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, relativeName); matched {
+			return true
+		}
+		if matched, _ := path.Match(glob, path.Base(relativeName)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIncluded returns true if a file with the relative name should be synced.
+func (f *syncFlagVal) isIncluded(relativeName string) bool {
+	if len(f.includeFlag) > 0 && !matchesAny(f.includeFlag, relativeName) {
+		return false
+	}
+
+	return !matchesAny(f.excludeFlag, relativeName)
+}
+
+// findEntries pairs the local files with the objects in the container, leaving out excluded files.
+func findEntries(connection *swift.Connection, container, localDir string, flagVals *syncFlagVal) ([]syncEntry, error) {
+	// A local directory that does not exist yet is empty, unless it is the source of an upload
+	files := make([]localFile, 0)
+	if _, err := os.Stat(localDir); !os.IsNotExist(err) || flagVals.directionFlag == directionUp {
+		files, err = findLocalFiles(localDir, flagVals.prefixFlag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	remote, err := findRemoteObjects(connection, container, flagVals.prefixFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*syncEntry, len(files))
+	for _, file := range files {
+		entries[file.objectName] = &syncEntry{file: file, hasLocal: true}
+	}
+
+	for name, remoteObject := range remote {
+		// Pseudo-directory marker objects have no local file
+		if strings.HasSuffix(name, "/") || remoteObject.ContentType == "application/directory" {
+			continue
+		}
+
+		entry, found := entries[name]
+		if !found {
+			filePath, err := localPath(localDir, flagVals.prefixFlag, name)
+			if err != nil {
+				return nil, err
+			}
+
+			entry = &syncEntry{file: localFile{path: filePath, objectName: name}}
+			entries[name] = entry
+		}
+
+		entry.remote = remoteObject
+		entry.hasRemote = true
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	included := make([]syncEntry, 0, len(names))
+	for _, name := range names {
+		entry := entries[name]
+		entry.relativeName = strings.TrimPrefix(name, flagVals.prefixFlag)
+		if flagVals.isIncluded(entry.relativeName) {
+			included = append(included, *entry)
+		}
+	}
+
+	return included, nil
+}
+
+// remoteModTime returns the modification time saved with an object, or else the time it was uploaded.
+func remoteModTime(connection *swift.Connection, container string, remoteObject swift.Object) time.Time {
+	_, headers, err := connection.Object(container, remoteObject.Name)
+	if err == nil {
+		if modTime, found := parseMtime(headers); found {
+			return modTime
+		}
+	}
+
+	return remoteObject.LastModified
+}
+
+// diff decides which change, if any, converges a file and its object.
+func diff(connection *swift.Connection, container string, entry syncEntry, flagVals *syncFlagVal) (*change, error) {
+	newChange := func(action, reason string) *change {
+		size := entry.file.size
+		if action == actionDownload || action == actionDeleteRemote {
+			size = entry.remote.Bytes
+		}
+
+		return &change{
+			Action: action,
+			Object: entry.file.objectName,
+			Path:   entry.file.path,
+			Bytes:  size,
+			Reason: reason,
+			entry:  entry,
+		}
+	}
+
+	switch {
+	case !entry.hasRemote:
+		if flagVals.directionFlag != directionDown {
+			return newChange(actionUpload, "new"), nil
+		} else if flagVals.deleteFlag {
+			return newChange(actionDeleteLocal, "missing from container"), nil
+		}
+		return nil, nil
+	case !entry.hasLocal:
+		if flagVals.directionFlag != directionUp {
+			return newChange(actionDownload, "new"), nil
+		} else if flagVals.deleteFlag {
+			return newChange(actionDeleteRemote, "missing from local directory"), nil
+		}
+		return nil, nil
+	}
+
+	// Files are unchanged if their size and MD5 match the object's
+	if entry.file.size == entry.remote.Bytes {
+		unchanged, err := isDownloaded(connection, container, entry.file, entry.remote.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if unchanged {
+			return nil, nil
+		}
+	}
+
+	switch flagVals.directionFlag {
+	case directionUp:
+		return newChange(actionUpload, "changed"), nil
+	case directionDown:
+		return newChange(actionDownload, "changed"), nil
+	}
+
+	// In both directions, the most recently modified side wins
+	if entry.file.modTime.After(remoteModTime(connection, container, entry.remote)) {
+		return newChange(actionUpload, "newer locally"), nil
+	}
+
+	return newChange(actionDownload, "newer in container"), nil
+}
+
+// apply makes a change, returning the error encountered, if any.
+func apply(dest auth.Destination, writer *w.ConsoleWriter, progress *w.TransferProgress, container string, c *change,
+	numThreads int) error {
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+	remote := map[string]swift.Object{}
+	if c.entry.hasRemote {
+		remote[c.Object] = c.entry.remote
+	}
+
+	var result transferResult
+	switch c.Action {
+	case actionUpload:
+		if uint(c.Bytes) > object.MaxObjectSize {
+			result = uploadLargeFile(dest, writer, progress, container, c.entry.file, remote, numThreads)
+		} else {
+			result = uploadFile(connection, progress, container, c.entry.file, remote)
+		}
+	case actionDownload:
+		result = downloadFile(connection, progress, container, localFile{
+			path:       c.Path,
+			objectName: c.Object,
+			size:       c.Bytes,
+		}, c.entry.remote.Hash)
+	case actionDeleteRemote:
+		err := connection.ObjectDelete(container, c.Object)
+		if err != nil {
+			return fmt.Errorf("Failed to delete object: %s", err)
+		}
+	case actionDeleteLocal:
+		err := os.Remove(c.Path)
+		if err != nil {
+			return fmt.Errorf("Failed to delete file: %s", err)
+		}
+	}
+
+	return result.err
+}
+
+// writeReport writes the JSON report of the changes to a file.
+func writeReport(reportPath string, report syncReport) error {
+	marshalledReport, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to JSON encode report: %s", err)
+	}
+
+	err = ioutil.WriteFile(reportPath, append(marshalledReport, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write report to %s: %s", reportPath, err)
+	}
+
+	return nil
+}
+
+// Sync converges a local directory and a container, or a pseudo-directory in it, by
+// uploading, downloading, and optionally deleting the files that differ.
+func Sync(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Comparing files with objects")
+
+	container := args[3]
+	localDir := args[4]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	flagVals, err := parseSyncFlags(args[5:])
+	if err != nil {
+		return "", err
+	}
+
+	// Downloading may populate a directory that does not exist yet
+	if flagVals.directionFlag != directionUp && !flagVals.dryRunFlag {
+		err = os.MkdirAll(localDir, 0755)
+		if err != nil {
+			return "", fmt.Errorf("Failed to create directory %s: %s", localDir, err)
+		}
+	}
+
+	entries, err := findEntries(connection, container, localDir, flagVals)
+	if err != nil {
+		return "", err
+	}
+
+	// Comparing files may require hashing them, so is done in parallel
+	changes := make([]*change, len(entries))
+	diffErrors := make([]error, len(entries))
+	runParallel(len(entries), flagVals.numThreadsFlag, func(i int) {
+		changes[i], diffErrors[i] = diff(connection, container, entries[i], flagVals)
+	})
+
+	report := syncReport{
+		Container: container,
+		Prefix:    flagVals.prefixFlag,
+		LocalDir:  localDir,
+		Direction: flagVals.directionFlag,
+		DryRun:    flagVals.dryRunFlag,
+		Changes:   make([]change, 0),
+	}
+
+	var totalSize int64
+	pending := make([]*change, 0)
+	for i, c := range changes {
+		if diffErrors[i] != nil {
+			return "", fmt.Errorf("Failed to compare %s: %s", entries[i].file.path, diffErrors[i])
+		}
+		if c == nil {
+			report.Unchanged++
+			continue
+		}
+		pending = append(pending, c)
+		if c.Action == actionUpload || c.Action == actionDownload {
+			totalSize += c.Bytes
+		}
+	}
+
+	if !flagVals.dryRunFlag {
+		downloads := make([]localFile, 0)
+		hashes := make(map[string]string)
+		for _, c := range pending {
+			if c.Action == actionDownload {
+				downloads = append(downloads, localFile{path: c.Path, objectName: c.Object})
+				hashes[c.Object] = c.entry.remote.Hash
+			}
+		}
+
+		err = removeStalePartials(downloads, hashes)
+		if err != nil {
+			return "", err
+		}
+
+		writer.SetCurrentStage(fmt.Sprintf("Syncing %d files", len(pending)))
+		progress := w.NewTransferProgress(totalSize)
+		writer.SetStatus(progress)
+
+		// Large uploads show their own progress, so are made one at a time after the rest
+		isLarge := func(c *change) bool {
+			return c.Action == actionUpload && uint(c.Bytes) > object.MaxObjectSize
+		}
+
+		runParallel(len(pending), flagVals.numThreadsFlag, func(i int) {
+			if !isLarge(pending[i]) {
+				if err := apply(dest, writer, progress, container, pending[i], flagVals.numThreadsFlag); err != nil {
+					pending[i].Error = err.Error()
+				}
+			}
+		})
+
+		for _, c := range pending {
+			if isLarge(c) {
+				writer.SetCurrentStage("Uploading " + c.Object + " as an SLO")
+				if err := apply(dest, writer, progress, container, c, flagVals.numThreadsFlag); err != nil {
+					c.Error = err.Error()
+				}
+			}
+		}
+	}
+
+	// Summarize the changes by action
+	counts := make(map[string]int)
+	details := ""
+	failed := 0
+	for _, c := range pending {
+		report.Changes = append(report.Changes, *c)
+
+		status := ""
+		if c.Error != "" {
+			status = " " + w.Red("failed: "+c.Error)
+			failed++
+		} else {
+			counts[c.Action]++
+		}
+		details += fmt.Sprintf("\t%-13s %s (%s)%s\n", c.Action, c.Object, c.Reason, status)
+	}
+
+	if flagVals.reportFlag != "" {
+		err = writeReport(flagVals.reportFlag, report)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	verb := "Synced"
+	if flagVals.dryRunFlag {
+		verb = "Would sync"
+	}
+	summary := fmt.Sprintf("%s %s with %s: %d uploaded, %d downloaded, %d deleted from container, %d deleted locally, %d unchanged\n",
+		verb, localDir, container, counts[actionUpload], counts[actionDownload], counts[actionDeleteRemote],
+		counts[actionDeleteLocal], report.Unchanged)
+
+	if failed > 0 {
+		return "", fmt.Errorf("%d of %d changes failed\n%s%s", failed, len(pending), details, summary)
+	}
+
+	return fmt.Sprintf("\r%s%s\n%s\n%s%s", w.ClearLine, w.Green("OK"), w.ClearLine, details, summary), nil
+}
@@ -0,0 +1,148 @@
+package expiry
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/swift"
+)
+
+// DeleteAtHeader is the header holding the Unix time at which Object Storage deletes an object.
+const DeleteAtHeader = "X-Delete-At"
+
+// metadataPrefix begins the names of headers holding an object's metadata.
+const metadataPrefix = "X-Object-Meta-"
+
+// PreservedHeaders are the headers, along with metadata, that a POST to an object
+// replaces, so must be sent again for the object to keep them.
+var PreservedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Expires",
+	"X-Delete-At",
+	"X-Object-Manifest",
+	"X-Robots-Tag",
+}
+
+// days matches durations beginning with a number of days, which time.ParseDuration does not accept.
+var days = regexp.MustCompile(`^(\d+)d(.*)$`)
+
+// FlagVal holds the values of the flags that schedule an object's deletion.
+type FlagVal struct {
+	expireAfterFlag string
+	expireAtFlag    string
+}
+
+// AddFlags defines the flags that schedule an object's deletion.
+func AddFlags(flagSet *flag.FlagSet) *FlagVal {
+	var flagVals FlagVal
+
+	flagSet.StringVar(&flagVals.expireAfterFlag, "expire-after", "", "Delete the object after this long, such as 30d or 12h")
+	flagSet.StringVar(&flagVals.expireAtFlag, "expire-at", "", "Delete the object at this RFC3339 time")
+
+	return &flagVals
+}
+
+// ParseDuration reads a duration such as 30d, 1d12h or 90m, allowing days as well as
+// the units accepted by time.ParseDuration.
+func ParseDuration(value string) (time.Duration, error) {
+	var duration time.Duration
+
+	if match := days.FindStringSubmatch(value); match != nil {
+		numDays, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, err
+		}
+		duration = time.Duration(numDays) * 24 * time.Hour
+
+		value = match[2]
+		if value == "" {
+			return duration, nil
+		}
+	}
+
+	rest, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return duration + rest, nil
+}
+
+// DeleteAt returns the value of the X-Delete-At header scheduled by the flags,
+// or an empty string if neither flag was given.
+func (f *FlagVal) DeleteAt() (string, error) {
+	var deleteAt time.Time
+
+	switch {
+	case f.expireAfterFlag != "" && f.expireAtFlag != "":
+		return "", fmt.Errorf("Please provide either -expire-after or -expire-at, not both")
+	case f.expireAfterFlag != "":
+		duration, err := ParseDuration(f.expireAfterFlag)
+		if err != nil || duration <= 0 {
+			return "", fmt.Errorf("Invalid expiry '%s' (must be a positive duration such as 30d, 12h or 1d12h)", f.expireAfterFlag)
+		}
+		deleteAt = time.Now().Add(duration)
+	case f.expireAtFlag != "":
+		var err error
+		deleteAt, err = time.Parse(time.RFC3339, f.expireAtFlag)
+		if err != nil {
+			return "", fmt.Errorf("Invalid expiry time '%s' (must be RFC3339, such as 2006-01-02T15:04:05Z): %s", f.expireAtFlag, err)
+		}
+		if !deleteAt.After(time.Now()) {
+			return "", fmt.Errorf("Expiry time %s is in the past", f.expireAtFlag)
+		}
+	default:
+		return "", nil
+	}
+
+	return strconv.FormatInt(deleteAt.Unix(), 10), nil
+}
+
+// Schedule sets the time at which Object Storage deletes an object, sending the
+// object's current metadata and preserved headers along with it.
+func Schedule(connection *swift.Connection, container, name, deleteAt string) error {
+	_, current, err := connection.Object(container, name)
+	if err != nil {
+		return fmt.Errorf("Failed to get object %s: %s", name, err)
+	}
+
+	headers := swift.Headers{}
+	for header, value := range current {
+		if strings.HasPrefix(header, metadataPrefix) {
+			headers[header] = value
+		}
+	}
+	for _, header := range PreservedHeaders {
+		if value, found := current[header]; found {
+			headers[header] = value
+		}
+	}
+	headers[DeleteAtHeader] = deleteAt
+
+	err = connection.ObjectUpdate(container, name, headers)
+	if err != nil {
+		return fmt.Errorf("Failed to schedule expiry of %s: %s", name, err)
+	}
+
+	return nil
+}
+
+// Describe returns the time, and how long until, an object with the given
+// X-Delete-At header value is deleted.
+func Describe(deleteAt string) (string, error) {
+	seconds, err := strconv.ParseInt(deleteAt, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s header '%s'", DeleteAtHeader, deleteAt)
+	}
+
+	expires := time.Unix(seconds, 0)
+
+	return fmt.Sprintf("%s (in %s)", expires.Format(time.RFC3339), time.Until(expires).Round(time.Second)), nil
+}
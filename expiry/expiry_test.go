@@ -0,0 +1,42 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"0d90m", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"0s", 0},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDuration(test.value)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) returned error: %s", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	values := []string{"", "d", "30", "1.5d", "d12h", "1d2d", "12h1d", "-1d"}
+
+	for _, value := range values {
+		if got, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) = %s, want error", value, got)
+		}
+	}
+}
@@ -7,26 +7,83 @@ import (
 )
 
 var (
+	// globalOptions describes the flags accepted by every subcommand that authenticates.
+	globalOptions = "   Global options:\n" +
+		"      -key key_name    Authenticate using the named service key\n" +
+		"      -create-key      Create a service key if the service has none\n" +
+		"      -auth-version n  Authenticate with Swift v1 (TempAuth), Keystone v2 or Keystone v3\n" +
+		"      -region name     Use the object-store endpoint in the named region\n" +
+		"      -interface type  Use the public, internal or admin object-store endpoint\n" +
+		"      -org name        Find the service in the named org rather than the targeted one\n" +
+		"      -space name      Find the service in the named space rather than the targeted one\n" +
+		"      -profile name    Use a saved profile's settings and omit service_name\n" +
+		"   Global options come before the subcommand's other arguments; none are read after --\n"
+
 	subcommands = []plugin.Command{
 		{
 			Name:     getAuthInfoCommand,
-			HelpText: "Authenticate with Object Storage and save credentials",
+			HelpText: "Authenticate with Object Storage, save credentials and list object-store endpoints",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + getAuthInfoCommand +
-					" service_name [--url] [-x]",
+					" service_name [--url] [-x] [-status]",
+				Options: map[string]string{
+					"url":    "Display auth url in quiet mode",
+					"x":      "Display x-auth token in quiet mode",
+					"status": "Show saved credentials instead of authenticating (service_name is optional)",
+				},
+			},
+		},
+		{
+			Name:     logoutCommand,
+			HelpText: "Revoke and remove a service's saved credentials",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + logoutCommand +
+					" service_name|-all",
 				Options: map[string]string{
-					"url": "Display auth url in quiet mode",
-					"x":   "Display x-auth token in quiet mode",
+					"all": "Log out of every service with saved credentials",
+				},
+			},
+		},
+		{
+			Name:     keysCommand,
+			HelpText: "List, create, rotate or delete the service keys managed by this plugin",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + keysCommand +
+					" service_name [list|create|rotate|delete [key_name]]",
+				Options: map[string]string{},
+			},
+		},
+		{
+			Name:     profileCommand,
+			HelpText: "List, show, save or delete named connection profiles",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + profileCommand +
+					" [list|show profile_name|delete profile_name|set profile_name" +
+					" (service_name [-org name] [-space name] [-key key_name] |" +
+					" -auth-url url -username name -password password [-project name] [-project-id id] [-domain name])" +
+					" [-region name] [-interface type] [-auth-version n]]",
+				Options: map[string]string{
+					"auth-url":   "Save raw Swift credentials with this auth url instead of a service",
+					"username":   "Swift username",
+					"password":   "Swift password (saved in the configured credential store)",
+					"project":    "Keystone project or tenant name",
+					"project-id": "Keystone project or tenant ID",
+					"domain":     "Keystone user domain name",
 				},
 			},
 		},
 		{
 			Name:     showContainersCommand,
-			HelpText: "Show all containers in an Object Storage instance",
+			HelpText: "Show a table of the containers in an Object Storage instance",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + showContainersCommand +
-					" service_name",
-				Options: map[string]string{},
+					" service_name [--sort name|count|bytes|modified] [--prefix prefix] [--limit n] [--marker container_name]",
+				Options: map[string]string{
+					"sort":   "Sort by name, or by object count, size or last-modified time with the largest or newest first",
+					"prefix": "Only show containers whose names begin with prefix",
+					"limit":  "Show at most n containers",
+					"marker": "Only show containers whose names follow container_name",
+				},
 			},
 		},
 		{
@@ -84,16 +141,23 @@ var (
 		},
 		{
 			Name:     showObjectsCommand,
-			HelpText: "Show all objects in a container",
+			HelpText: "Show the objects and pseudo-directories in a container",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + showObjectsCommand +
-					" service_name container_name",
-				Options: map[string]string{},
+					" service_name container_name [--prefix prefix] [--delimiter char] [--recursive] [--long] [--limit n] [--marker object_name]",
+				Options: map[string]string{
+					"prefix":    "Only show objects whose names begin with prefix, such as a pseudo-directory",
+					"delimiter": "Character separating pseudo-directories (defaults to /)",
+					"recursive": "List every object beneath the prefix instead of pseudo-directories",
+					"long":      "Show each object's size, hash, content type and last-modified time",
+					"limit":     "Show at most n entries (defaults to 1000, 0 for no limit)",
+					"marker":    "Only show entries whose names follow object_name",
+				},
 			},
 		},
 		{
 			Name:     objectInfoCommand,
-			HelpText: "Show a given object's information",
+			HelpText: "Show a given object's information, including when it expires",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + objectInfoCommand +
 					" service_name container_name object_name",
@@ -102,20 +166,61 @@ var (
 		},
 		{
 			Name:     putObjectCommand,
-			HelpText: "Upload a file as an object to Object Storage",
+			HelpText: "Upload a file, or stdin with a path of -, as an object to Object Storage",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + putObjectCommand +
-					" service_name container_name path_to_local_file [-n object_name]",
-				Options: map[string]string{},
+					" service_name container_name path_to_local_file [headers...] [-n object_name] [-threshold bytes] [-s chunk_size] [-t num_threads]" +
+					" [--meta key=value] [--content-type type] [--content-encoding encoding] [--content-disposition disposition] [--cache-control directives]" +
+					" [--expire-after duration | --expire-at time]",
+				Options: map[string]string{
+					"n":                   "Name of the object (defaults to the file's name, and is required when uploading from stdin)",
+					"threshold":           "Size, in bytes, above which the file or stdin is uploaded as an SLO (defaults to the 5GB maximum object size)",
+					"s":                   "SLO chunk size, in bytes (defaults to 1GB or the threshold, whichever is smaller, or larger for very large files)",
+					"t":                   "Maximum number of SLO uploader threads (defaults to the available number of CPUs)",
+					"meta":                "Metadata to set on the object as an X-Object-Meta- header, and may be repeated",
+					"content-type":        "Content type of the object (detected from its name or contents by default)",
+					"content-encoding":    "Content encoding of the object, such as gzip",
+					"content-disposition": "Content disposition of the object, such as attachment; filename=report.pdf",
+					"cache-control":       "Cache control directives of the object, such as max-age=3600",
+					"expire-after":        "Delete the object, and the segments of an SLO, after this long, such as 30d, 12h or 1d12h",
+					"expire-at":           "Delete the object, and the segments of an SLO, at this RFC3339 time, such as 2006-01-02T15:04:05Z",
+				},
 			},
 		},
 		{
 			Name:     getObjectCommand,
-			HelpText: "Download an object from Object Storage",
+			HelpText: "Download an object from Object Storage, or to stdout with a path of -, verifying it and resuming interrupted downloads",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + getObjectCommand +
-					" service_name container_name object_name path_to_dl_location",
-				Options: map[string]string{},
+					" service_name container_name object_name path_to_dl_location [--range first-last]",
+				Options: map[string]string{
+					"range": "Only download the given bytes, such as 0-1023, 1024- or -512 (the last 512 bytes)",
+				},
+			},
+		},
+		{
+			Name:     updateObjectCommand,
+			HelpText: "Update the metadata and headers of an object, or of every object beginning with a prefix, without re-uploading it",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + updateObjectCommand +
+					" service_name container_name (object_name | -prefix prefix) [headers...] [--meta key=value] [--remove-meta key]" +
+					" [--content-type type] [--content-encoding encoding] [--content-disposition disposition] [--cache-control directives]" +
+					" [-attachment] [-inline] [-no-cache] [--expire-after duration | --expire-at time] [-t num_threads]",
+				Options: map[string]string{
+					"prefix":              "Update every object beginning with this prefix instead of a single object",
+					"meta":                "Metadata to add or replace as an X-Object-Meta- header, and may be repeated",
+					"remove-meta":         "Metadata key to remove, and may be repeated",
+					"content-type":        "Content type of the object",
+					"content-encoding":    "Content encoding of the object, such as gzip",
+					"content-disposition": "Content disposition of the object, such as attachment; filename=report.pdf",
+					"cache-control":       "Cache control directives of the object, such as max-age=3600",
+					"attachment":          "Short name for downloading as an attachment header",
+					"inline":              "Short name for displaying inline header",
+					"no-cache":            "Short name for no caching header",
+					"expire-after":        "Delete the object, and the segments of an SLO, after this long, such as 30d, 12h or 1d12h",
+					"expire-at":           "Delete the object, and the segments of an SLO, at this RFC3339 time, such as 2006-01-02T15:04:05Z",
+					"t":                   "Number of objects to update at once (defaults to the available number of CPUs)",
+				},
 			},
 		},
 		{
@@ -164,12 +269,109 @@ var (
 			HelpText: "Create a Static Large Object in Object Storage",
 			UsageDetails: plugin.Usage{
 				Usage: "cf " + namespace + " " + makeSLOCommand +
-					" service_name slo_container slo_name source_file [-m] [-o output_file] [-s chunk_size] [-j num_threads]",
+					" service_name slo_container slo_name source_file [-m] [-o output_file] [-s chunk_size] [-j num_threads]" +
+					" [--expire-after duration | --expire-at time]",
+				Options: map[string]string{
+					"m":            "Only upload missing chunks",
+					"o":            "Destination for log data, if desired",
+					"s":            "Chunk size, in bytes (defaults to create 1GB chunks)",
+					"j":            "Maximum number of uploader threads (defaults to the available number of CPUs)",
+					"expire-after": "Delete the SLO and its segments after this long, such as 30d, 12h or 1d12h",
+					"expire-at":    "Delete the SLO and its segments at this RFC3339 time, such as 2006-01-02T15:04:05Z",
+				},
+			},
+		},
+		{
+			Name:     putDirCommand,
+			HelpText: "Upload every file in a local directory, skipping unchanged files",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + putDirCommand +
+					" service_name container_name local_dir [-p prefix] [-t num_threads]",
+				Options: map[string]string{
+					"p": "Prefix added to the name of every object, such as a pseudo-directory",
+					"t": "Number of files to upload at once (defaults to the available number of CPUs)",
+				},
+			},
+		},
+		{
+			Name:     getDirCommand,
+			HelpText: "Download every object in a container, or beneath a prefix, to a local directory, skipping unchanged files",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + getDirCommand +
+					" service_name container_name [prefix] local_dir [-t num_threads]",
+				Options: map[string]string{
+					"t": "Number of objects to download at once (defaults to the available number of CPUs)",
+				},
+			},
+		},
+		{
+			Name:     syncCommand,
+			HelpText: "Upload, download or delete files to make a local directory and a container match",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + syncCommand +
+					" service_name container_name local_dir [-p prefix] [-direction up|down|both] [-delete] [-dry-run]" +
+					" [-include glob]... [-exclude glob]... [-report report_file] [-t num_threads]",
+				Options: map[string]string{
+					"p":         "Pseudo-directory in the container to sync with",
+					"direction": "Upload local changes (up, the default), download remote changes (down), or copy whichever side was modified last (both)",
+					"delete":    "Delete files or objects that are missing from the source (not allowed with -direction both)",
+					"dry-run":   "Only list the changes that would be made",
+					"include":   "Only sync files whose path or name matches the glob (may be repeated)",
+					"exclude":   "Do not sync files whose path or name matches the glob (may be repeated)",
+					"report":    "Write a JSON report of the changes to report_file",
+					"t":         "Number of files to compare and transfer at once (defaults to the available number of CPUs)",
+				},
+			},
+		},
+		{
+			Name:     tempUrlKeyCommand,
+			HelpText: "Show, set, or rotate the keys that sign temp URLs for the account or a container",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + tempUrlKeyCommand +
+					" service_name (show | set [key] [-2] | rotate) [-container container_name]",
+				Options: map[string]string{
+					"show":      "Show the keys",
+					"set":       "Set the key, generating a random one if none is given",
+					"2":         "Set the second key rather than the first",
+					"rotate":    "Replace the key with a random one, keeping the previous key as the second key so that URLs signed with it remain valid",
+					"container": "Manage the keys of this container instead of the account's",
+				},
+			},
+		},
+		{
+			Name:     tempUrlCommand,
+			HelpText: "Sign a URL that allows access to an object, or to objects beginning with a prefix, until it expires",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + tempUrlCommand +
+					" service_name container_name (object_name | -prefix prefix) [-method GET|PUT|HEAD] [-lifetime duration]" +
+					" [-digest sha1|sha256] [-inline] [-filename name] [-sign-key key]",
+				Options: map[string]string{
+					"prefix":   "Sign the URL for every object beginning with this prefix instead of a single object",
+					"method":   "Request method the URL allows (defaults to GET)",
+					"lifetime": "How long the URL is valid, such as 1h or 7d (defaults to 1h)",
+					"digest":   "Digest used to sign the URL (defaults to sha256)",
+					"inline":   "Have browsers display the object rather than download it",
+					"filename": "File name browsers save the object as",
+					"sign-key": "Sign with this key instead of the account's or container's temp URL key",
+				},
+			},
+		},
+		{
+			Name:     formPostCommand,
+			HelpText: "Sign the fields of an HTML form that lets browsers upload files directly into a container",
+			UsageDetails: plugin.Usage{
+				Usage: "cf " + namespace + " " + formPostCommand +
+					" service_name container_name [-prefix prefix] [-redirect url] [-max-file-size bytes] [-max-file-count count]" +
+					" [-lifetime duration] [-digest sha1|sha256] [-sign-key key] [-html html_file]",
 				Options: map[string]string{
-					"m": "Only upload missing chunks",
-					"o": "Destination for log data, if desired",
-					"s": "Chunk size, in bytes (defaults to create 1GB chunks)",
-					"j": "Maximum number of uploader threads (defaults to the available number of CPUs)",
+					"prefix":         "Prefix added to the names of uploaded objects",
+					"redirect":       "URL browsers are sent to after uploading",
+					"max-file-size":  "Largest file, in bytes, that may be uploaded (defaults to 5GB)",
+					"max-file-count": "Most files that may be uploaded at once (defaults to 1)",
+					"lifetime":       "How long the form is valid, such as 1h or 7d (defaults to 1h)",
+					"digest":         "Digest used to sign the form (defaults to sha256)",
+					"sign-key":       "Sign with this key instead of the account's or container's temp URL key",
+					"html":           "Write a ready-to-use HTML form to html_file",
 				},
 			},
 		},
@@ -177,21 +379,31 @@ var (
 
 	subcommandMap = map[string]plugin.Command{
 		getAuthInfoCommand:     subcommands[0],
-		showContainersCommand:  subcommands[1],
-		containerInfoCommand:   subcommands[2],
-		makeContainerCommand:   subcommands[3],
-		updateContainerCommand: subcommands[4],
-		renameContainerCommand: subcommands[5],
-		deleteContainerCommand: subcommands[6],
-		showObjectsCommand:     subcommands[7],
-		objectInfoCommand:      subcommands[8],
-		putObjectCommand:       subcommands[9],
-		getObjectCommand:       subcommands[10],
-		renameObjectCommand:    subcommands[11],
-		copyObjectCommand:      subcommands[12],
-		deleteObjectCommand:    subcommands[13],
-		makeDLOCommand:         subcommands[14],
-		makeSLOCommand:         subcommands[15],
+		logoutCommand:          subcommands[1],
+		keysCommand:            subcommands[2],
+		profileCommand:         subcommands[3],
+		showContainersCommand:  subcommands[4],
+		containerInfoCommand:   subcommands[5],
+		makeContainerCommand:   subcommands[6],
+		updateContainerCommand: subcommands[7],
+		renameContainerCommand: subcommands[8],
+		deleteContainerCommand: subcommands[9],
+		showObjectsCommand:     subcommands[10],
+		objectInfoCommand:      subcommands[11],
+		putObjectCommand:       subcommands[12],
+		getObjectCommand:       subcommands[13],
+		updateObjectCommand:    subcommands[14],
+		renameObjectCommand:    subcommands[15],
+		copyObjectCommand:      subcommands[16],
+		deleteObjectCommand:    subcommands[17],
+		makeDLOCommand:         subcommands[18],
+		makeSLOCommand:         subcommands[19],
+		putDirCommand:          subcommands[20],
+		getDirCommand:          subcommands[21],
+		syncCommand:            subcommands[22],
+		tempUrlKeyCommand:      subcommands[23],
+		tempUrlCommand:         subcommands[24],
+		formPostCommand:        subcommands[25],
 	}
 )
 
@@ -226,6 +438,9 @@ func (c *ObjectStoragePlugin) help(args []string) error {
 		help := "Please provide a valid subcommand\n" +
 			"Available subcommands:\n" +
 			"      " + getAuthInfoCommand + "\n" +
+			"      " + logoutCommand + "\n" +
+			"      " + keysCommand + "\n" +
+			"      " + profileCommand + "\n" +
 			"      " + showContainersCommand + "\n" +
 			"      " + containerInfoCommand + "\n" +
 			"      " + makeContainerCommand + "\n" +
@@ -236,11 +451,19 @@ func (c *ObjectStoragePlugin) help(args []string) error {
 			"      " + objectInfoCommand + "\n" +
 			"      " + putObjectCommand + "\n" +
 			"      " + getObjectCommand + "\n" +
+			"      " + updateObjectCommand + "\n" +
 			"      " + renameObjectCommand + "\n" +
 			"      " + copyObjectCommand + "\n" +
 			"      " + deleteObjectCommand + "\n" +
 			"      " + makeDLOCommand + "\n" +
-			"      " + makeSLOCommand + "\n"
+			"      " + makeSLOCommand + "\n" +
+			"      " + putDirCommand + "\n" +
+			"      " + getDirCommand + "\n" +
+			"      " + syncCommand + "\n" +
+			"      " + tempUrlKeyCommand + "\n" +
+			"      " + tempUrlCommand + "\n" +
+			"      " + formPostCommand + "\n" +
+			globalOptions
 
 		fmt.Print(help)
 
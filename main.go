@@ -7,9 +7,11 @@ import (
 	"github.com/cloudfoundry/cli/plugin"
 	"github.com/ibmjstart/cf-object-storage/authenticate"
 	"github.com/ibmjstart/cf-object-storage/container"
+	"github.com/ibmjstart/cf-object-storage/directory"
 	"github.com/ibmjstart/cf-object-storage/dlo"
 	"github.com/ibmjstart/cf-object-storage/object"
 	"github.com/ibmjstart/cf-object-storage/slo"
+	"github.com/ibmjstart/cf-object-storage/tempurl"
 	w "github.com/ibmjstart/cf-object-storage/writer"
 	"github.com/ibmjstart/swiftlygo/auth"
 )
@@ -28,6 +30,15 @@ const (
 	// Name of the subcommand that fetches X-Auth Tokens
 	getAuthInfoCommand string = "auth"
 
+	// Name of the subcommand that removes saved X-Auth Tokens
+	logoutCommand string = "logout"
+
+	// Name of the subcommand that manages the plugin's service keys
+	keysCommand string = "keys"
+
+	// Name of the subcommand that manages named connection profiles
+	profileCommand string = "profile"
+
 	// Names of the container subcommands
 	showContainersCommand  string = "containers"
 	containerInfoCommand   string = "container"
@@ -41,6 +52,7 @@ const (
 	objectInfoCommand   string = "object"
 	putObjectCommand    string = "put-object"
 	getObjectCommand    string = "get-object"
+	updateObjectCommand string = "update-object"
 	renameObjectCommand string = "rename-object"
 	copyObjectCommand   string = "copy-object"
 	deleteObjectCommand string = "delete-object"
@@ -48,6 +60,16 @@ const (
 	// Names of the subcommands that create large objects in object storage
 	makeDLOCommand string = "create-dynamic-object"
 	makeSLOCommand string = "put-large-object"
+
+	// Names of the subcommands that transfer whole directories
+	putDirCommand string = "put-dir"
+	getDirCommand string = "get-dir"
+	syncCommand   string = "sync"
+
+	// Names of the subcommands that grant access to objects without credentials
+	tempUrlKeyCommand string = "temp-url-key"
+	tempUrlCommand    string = "temp-url"
+	formPostCommand   string = "form-post"
 )
 
 // ObjectStoragePlugin is the struct implementing the plugin interface.
@@ -58,16 +80,25 @@ type ObjectStoragePlugin struct {
 	writer        *w.ConsoleWriter
 }
 
-// command contains the info needed to execute a subcommand.
+// command contains the info needed to execute a subcommand. Subcommands that
+// work with Cloud Foundry rather than Object Storage set executeWithCli instead
+// of execute, and are run without authenticating.
 type command struct {
 	name            string
 	task            string
 	numExpectedArgs int
 	execute         func(auth.Destination, *w.ConsoleWriter, []string) (string, error)
+	executeWithCli  func(plugin.CliConnection, *w.ConsoleWriter, authenticate.Options, []string) (string, error)
 }
 
 // displayUserInfo shows the username, org and space corresponding to the requested service.
-func displayUserInfo(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, task string) error {
+func displayUserInfo(cliConnection plugin.CliConnection, writer *w.ConsoleWriter, task string, options authenticate.Options) error {
+	// Credentials may come from the environment or a profile when not logged in to Cloud Foundry
+	if loggedIn, err := cliConnection.IsLoggedIn(); options.UsesSwiftCredentials() || (err == nil && !loggedIn) {
+		writer.Print("%s Object Storage...\n", task)
+		return nil
+	}
+
 	// Find username
 	username, err := cliConnection.Username()
 	if err != nil {
@@ -86,32 +117,73 @@ func displayUserInfo(cliConnection plugin.CliConnection, writer *w.ConsoleWriter
 		return fmt.Errorf("Failed to get space: %s", err)
 	}
 
-	writer.Print("%s org %s / space %s as %s...\n", task, w.Cyan(org.Name), w.Cyan(space.Name), w.Cyan(username))
+	// The org and space flags, or a profile, may name a target other than the current one
+	orgName, spaceName := org.Name, space.Name
+	if options.Org != "" {
+		orgName = options.Org
+	}
+	if options.Space != "" {
+		spaceName = options.Space
+	}
+
+	writer.Print("%s org %s / space %s as %s...\n", task, w.Cyan(orgName), w.Cyan(spaceName), w.Cyan(username))
 
 	return nil
 }
 
 // executeCommand authenticates with Object Storage and runs a command
 func (c *ObjectStoragePlugin) executeCommand(cmd command, args []string) error {
+	args, options, err := authenticate.ExtractOptions(args)
+	if err != nil {
+		return err
+	}
+
+	// A profile supplies the service name in place of the service_name argument
+	if options.Profile != "" && cmd.name != profileCommand {
+		var serviceName string
+		options, serviceName, err = authenticate.ResolveProfile(options)
+		if err != nil {
+			return err
+		}
+		args = append([]string{args[0], args[1], serviceName}, args[2:]...)
+	}
+
+	// The status of saved credentials is shown without authenticating
+	if cmd.name == getAuthInfoCommand && authenticate.IsStatusRequest(args) {
+		cmd.task = "Showing saved credentials for"
+		cmd.executeWithCli = authenticate.DisplayStatus
+	}
+
 	if len(args) < cmd.numExpectedArgs {
 		help, _ := getSubcommandHelp(cmd.name)
 		return fmt.Errorf("Missing required arguments\n%s", help)
 	}
 
-	err := displayUserInfo(c.cliConnection, c.writer, cmd.task)
+	// Objects downloaded to stdout must not be mixed with the command's progress output
+	if cmd.name == getObjectCommand && len(args) > 5 && args[5] == "-" {
+		c.writer.UseStderr()
+	}
+
+	err = displayUserInfo(c.cliConnection, c.writer, cmd.task, options)
 	if err != nil {
 		return err
 	}
 
 	go c.writer.Write()
 
-	serviceName := args[2]
-	destination, err := authenticate.Authenticate(c.cliConnection, c.writer, serviceName)
-	if err != nil {
-		return err
-	}
+	var result string
+	if cmd.executeWithCli != nil {
+		result, err = cmd.executeWithCli(c.cliConnection, c.writer, options, args)
+	} else {
+		var destination auth.Destination
+		serviceName := args[2]
+		destination, err = authenticate.Authenticate(c.cliConnection, c.writer, serviceName, options)
+		if err != nil {
+			return err
+		}
 
-	result, err := cmd.execute(destination, c.writer, args)
+		result, err = cmd.execute(destination, c.writer, args)
+	}
 	if err != nil {
 		return err
 	}
@@ -136,6 +208,24 @@ func (c *ObjectStoragePlugin) Run(cliConnection plugin.CliConnection, args []str
 			numExpectedArgs: 3,
 			execute:         authenticate.DisplayAuthInfo,
 		},
+		logoutCommand: command{
+			name:            logoutCommand,
+			task:            "Logging out of Object Storage in",
+			numExpectedArgs: 3,
+			executeWithCli:  authenticate.Logout,
+		},
+		keysCommand: command{
+			name:            keysCommand,
+			task:            "Managing service keys in",
+			numExpectedArgs: 3,
+			executeWithCli:  authenticate.ManageKeys,
+		},
+		profileCommand: command{
+			name:            profileCommand,
+			task:            "Managing connection profiles for",
+			numExpectedArgs: 2,
+			executeWithCli:  authenticate.ManageProfiles,
+		},
 
 		// Container commands
 		showContainersCommand: command{
@@ -200,6 +290,12 @@ func (c *ObjectStoragePlugin) Run(cliConnection plugin.CliConnection, args []str
 			numExpectedArgs: 6,
 			execute:         object.GetObject,
 		},
+		updateObjectCommand: command{
+			name:            updateObjectCommand,
+			task:            "Updating object in",
+			numExpectedArgs: 4,
+			execute:         object.UpdateObject,
+		},
 		renameObjectCommand: command{
 			name:            renameObjectCommand,
 			task:            "Renaming object in",
@@ -232,6 +328,46 @@ func (c *ObjectStoragePlugin) Run(cliConnection plugin.CliConnection, args []str
 			numExpectedArgs: 6,
 			execute:         slo.MakeSlo,
 		},
+
+		// Directory commands
+		putDirCommand: command{
+			name:            putDirCommand,
+			task:            "Uploading directory to",
+			numExpectedArgs: 5,
+			execute:         directory.PutDir,
+		},
+		getDirCommand: command{
+			name:            getDirCommand,
+			task:            "Downloading directory from",
+			numExpectedArgs: 5,
+			execute:         directory.GetDir,
+		},
+		syncCommand: command{
+			name:            syncCommand,
+			task:            "Syncing directory with",
+			numExpectedArgs: 5,
+			execute:         directory.Sync,
+		},
+
+		// Temporary access commands
+		tempUrlKeyCommand: command{
+			name:            tempUrlKeyCommand,
+			task:            "Managing temp URL keys of",
+			numExpectedArgs: 4,
+			execute:         tempurl.ManageTempUrlKeys,
+		},
+		tempUrlCommand: command{
+			name:            tempUrlCommand,
+			task:            "Signing temp URL for",
+			numExpectedArgs: 4,
+			execute:         tempurl.MakeTempUrl,
+		},
+		formPostCommand: command{
+			name:            formPostCommand,
+			task:            "Signing form for uploads to",
+			numExpectedArgs: 4,
+			execute:         tempurl.MakeFormPost,
+		},
 	}
 
 	// Create writer to provide output
@@ -267,6 +403,9 @@ func (c *ObjectStoragePlugin) GetMetadata() plugin.PluginMetadata {
 	var usageContent = "cf " + namespace + " COMMAND [ARGS...] \n" +
 		"\n   Object Storage commands:\n" +
 		"      " + getAuthInfoCommand + "\n" +
+		"      " + logoutCommand + "\n" +
+		"      " + keysCommand + "\n" +
+		"      " + profileCommand + "\n" +
 		"      " + showContainersCommand + "\n" +
 		"      " + containerInfoCommand + "\n" +
 		"      " + makeContainerCommand + "\n" +
@@ -277,11 +416,19 @@ func (c *ObjectStoragePlugin) GetMetadata() plugin.PluginMetadata {
 		"      " + objectInfoCommand + "\n" +
 		"      " + putObjectCommand + "\n" +
 		"      " + getObjectCommand + "\n" +
+		"      " + updateObjectCommand + "\n" +
 		"      " + renameObjectCommand + "\n" +
 		"      " + copyObjectCommand + "\n" +
 		"      " + deleteObjectCommand + "\n" +
 		"      " + makeDLOCommand + "\n" +
 		"      " + makeSLOCommand + "\n" +
+		"      " + putDirCommand + "\n" +
+		"      " + getDirCommand + "\n" +
+		"      " + syncCommand + "\n" +
+		"      " + tempUrlKeyCommand + "\n" +
+		"      " + tempUrlCommand + "\n" +
+		"      " + formPostCommand + "\n" +
+		globalOptions +
 		"   For more detailed information on subcommands use 'cf os help subcommand'"
 
 	return plugin.PluginMetadata{
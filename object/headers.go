@@ -0,0 +1,145 @@
+package object
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ncw/swift"
+)
+
+// metadataPrefix begins the names of headers holding an object's metadata.
+const metadataPrefix = "X-Object-Meta-"
+
+// sniffLength is the number of bytes examined to detect a content type.
+const sniffLength = 512
+
+// metadataList is a repeatable flag holding key=value object metadata.
+type metadataList map[string]string
+
+func (m metadataList) String() string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (m metadataList) Set(pair string) error {
+	keyValue := strings.SplitN(pair, "=", 2)
+	if len(keyValue) != 2 || keyValue[0] == "" {
+		return fmt.Errorf("metadata must use format key=value")
+	}
+
+	m[keyValue[0]] = keyValue[1]
+
+	return nil
+}
+
+// headerFlagVal holds the values of the flags that set an object's headers.
+type headerFlagVal struct {
+	metaFlag               metadataList
+	contentTypeFlag        string
+	contentEncodingFlag    string
+	contentDispositionFlag string
+	cacheControlFlag       string
+}
+
+// addHeaderFlags defines the flags that set an object's headers.
+func addHeaderFlags(flagSet *flag.FlagSet) *headerFlagVal {
+	flagVals := headerFlagVal{metaFlag: make(metadataList)}
+
+	flagSet.Var(flagVals.metaFlag, "meta", "Metadata to set on the object, as key=value")
+	flagSet.StringVar(&flagVals.contentTypeFlag, "content-type", "", "Content type of the object")
+	flagSet.StringVar(&flagVals.contentEncodingFlag, "content-encoding", "", "Content encoding of the object, such as gzip")
+	flagSet.StringVar(&flagVals.contentDispositionFlag, "content-disposition", "", "Content disposition of the object")
+	flagSet.StringVar(&flagVals.cacheControlFlag, "cache-control", "", "Cache control of the object")
+
+	return &flagVals
+}
+
+// apply adds the headers set by the flags to the given headers, overriding any already there.
+func (f *headerFlagVal) apply(headers swift.Headers) {
+	for key, value := range f.metaFlag {
+		headers[http.CanonicalHeaderKey(metadataPrefix+key)] = value
+	}
+
+	flagHeaders := map[string]string{
+		"Content-Type":        f.contentTypeFlag,
+		"Content-Encoding":    f.contentEncodingFlag,
+		"Content-Disposition": f.contentDispositionFlag,
+		"Cache-Control":       f.cacheControlFlag,
+	}
+	for name, value := range flagHeaders {
+		if value != "" {
+			headers[name] = value
+		}
+	}
+}
+
+// parseHeader reads a header given in the format header-name:header-value.
+func parseHeader(h string) (string, string, error) {
+	headerPair := strings.SplitN(h, ":", 2)
+	if len(headerPair) != 2 || headerPair[0] == "" {
+		return "", "", fmt.Errorf("Unable to parse headers (must use format header-name:header-value)")
+	}
+
+	return headerPair[0], headerPair[1], nil
+}
+
+// parseWithHeaders parses flags that may be interleaved with headers, returning
+// the headers. Shortcuts stand for the header they map to, and are replaced
+// before parsing so that they are not mistaken for flags. Header names are
+// canonicalized so that they match the names used by swift and net/http.
+func parseWithHeaders(flagSet *flag.FlagSet, args []string, shortcuts map[string]string) (swift.Headers, error) {
+	headers := swift.Headers{}
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if hFromMap, found := shortcuts[arg]; found {
+			arg = hFromMap
+		}
+		expanded[i] = arg
+	}
+	args = expanded
+
+	for {
+		err := flagSet.Parse(args)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse flags: %s", err)
+		}
+
+		args = flagSet.Args()
+		if len(args) == 0 {
+			return headers, nil
+		}
+
+		name, value, err := parseHeader(args[0])
+		if err != nil {
+			return nil, err
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+
+		args = args[1:]
+	}
+}
+
+// detectContentType returns the content type suggested by an object's name, or
+// else by sniffing the start of its contents without consuming them.
+func detectContentType(objectName string, source *bufio.Reader) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(objectName)); contentType != "" {
+		return contentType
+	}
+
+	// A short source returns what it has along with an error, which is enough to sniff
+	start, _ := source.Peek(sniffLength)
+
+	return http.DetectContentType(start)
+}
@@ -0,0 +1,64 @@
+package object
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseWithHeaders(t *testing.T) {
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{[]string{}, map[string]string{}},
+		{[]string{"Content-Type:text/csv"}, map[string]string{"Content-Type": "text/csv"}},
+		// Header names are canonicalized so that they replace, rather than duplicate, detected headers
+		{[]string{"content-type:text/csv"}, map[string]string{"Content-Type": "text/csv"}},
+		{[]string{"x-object-meta-color:blue"}, map[string]string{"X-Object-Meta-Color": "blue"}},
+		{[]string{"X-Object-Meta-Url:http://example.com"}, map[string]string{"X-Object-Meta-Url": "http://example.com"}},
+		{[]string{"Cache-Control:no-cache", "-n", "name", "X-Delete-At:1512508563"},
+			map[string]string{"Cache-Control": "no-cache", "X-Delete-At": "1512508563"}},
+		{[]string{"-n", "name", "Content-Encoding:gzip", "-t", "4"}, map[string]string{"Content-Encoding": "gzip"}},
+		{[]string{"Empty-Value:"}, map[string]string{"Empty-Value": ""}},
+		{[]string{"ct", "-n", "name"}, map[string]string{"Content-Type": "text/plain"}},
+	}
+
+	for _, test := range tests {
+		flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+		flagSet.String("n", "", "")
+		flagSet.Int("t", 1, "")
+
+		got, err := parseWithHeaders(flagSet, test.args, map[string]string{"ct": "content-type:text/plain"})
+		if err != nil {
+			t.Errorf("parseWithHeaders(%q) returned error: %s", test.args, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("parseWithHeaders(%q) = %v, want %v", test.args, got, test.want)
+			continue
+		}
+		for name, value := range test.want {
+			if gotValue, found := got[name]; !found || gotValue != value {
+				t.Errorf("parseWithHeaders(%q) = %v, want %v", test.args, got, test.want)
+			}
+		}
+	}
+}
+
+func TestParseWithHeadersInvalid(t *testing.T) {
+	tests := [][]string{
+		{"no-colon"},
+		{":value"},
+		{"Content-Type:text/csv", "-unknown"},
+	}
+
+	for _, args := range tests {
+		flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+
+		if got, err := parseWithHeaders(flagSet, args, nil); err == nil {
+			t.Errorf("parseWithHeaders(%q) = %v, want error", args, got)
+		}
+	}
+}
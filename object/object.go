@@ -1,21 +1,33 @@
 package object
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/ibmjstart/cf-object-storage/expiry"
+	"github.com/ibmjstart/cf-object-storage/slo"
 	w "github.com/ibmjstart/cf-object-storage/writer"
 	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
 )
 
-// maxObjectSize is the largest size a file can be in Object Storage.
-const maxObjectSize uint = 1000 * 1000 * 1000 * 5
+// MaxObjectSize is the largest size a file can be in Object Storage.
+const MaxObjectSize uint = 1000 * 1000 * 1000 * 5
 
 // GetObjectInfo returns metadata for a given object.
 func GetObjectInfo(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
@@ -30,9 +42,17 @@ func GetObjectInfo(dest auth.Destination, writer *w.ConsoleWriter, args []string
 	}
 
 	retval := fmt.Sprintf("\r%s%s\n\nName: %s\nContent type: %s\nSize: %d bytes\nLast modified: %s\n"+
-		"Hash: %s\nIs pseudo dir: %t\nSubdirectory: \n%sHeaders:", w.ClearLine, w.Green("OK"),
+		"Hash: %s\nIs pseudo dir: %t\nSubdirectory: \n%s", w.ClearLine, w.Green("OK"),
 		objectInfo.Name, objectInfo.ContentType, objectInfo.Bytes, objectInfo.ServerLastModified,
 		objectInfo.Hash, objectInfo.PseudoDirectory, objectInfo.SubDir)
+	if deleteAt, found := headers[expiry.DeleteAtHeader]; found {
+		expires, err := expiry.Describe(deleteAt)
+		if err != nil {
+			return "", err
+		}
+		retval += fmt.Sprintf("Expires: %s\n", expires)
+	}
+	retval += "Headers:"
 	for k, h := range headers {
 		retval += fmt.Sprintf("\n\tName: %s Value: %s", k, h)
 	}
@@ -41,56 +61,485 @@ func GetObjectInfo(dest auth.Destination, writer *w.ConsoleWriter, args []string
 	return retval, nil
 }
 
-// ShowObjects returns the names of all objects in a given container.
+// listingPageSize is the largest number of objects requested from a container listing at once.
+const listingPageSize = 10000
+
+// defaultListLimit is the number of objects listed when no limit is given, so that
+// browsing a very large container does not fetch its entire listing.
+const defaultListLimit = 1000
+
+// listFlagVal holds the flag values of the objects subcommand.
+type listFlagVal struct {
+	prefixFlag    string
+	delimiterFlag rune
+	markerFlag    string
+	limitFlag     int
+	longFlag      bool
+	recursiveFlag bool
+}
+
+// parseListFlags reads the flags provided to the objects subcommand.
+func parseListFlags(args []string) (*listFlagVal, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	prefix := flagSet.String("prefix", "", "Only show objects beginning with this prefix")
+	delimiter := flagSet.String("delimiter", "/", "Character separating pseudo-directories")
+	marker := flagSet.String("marker", "", "Only show objects after this name")
+	limit := flagSet.Int("limit", defaultListLimit, "Show at most this many objects (0 for no limit)")
+	long := flagSet.Bool("long", false, "Show each object's size, hash, content type and last-modified time")
+	recursive := flagSet.Bool("recursive", false, "List every object beneath the prefix instead of pseudo-directories")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	delimiterRunes := []rune(*delimiter)
+	if len(delimiterRunes) != 1 {
+		return nil, fmt.Errorf("Delimiter must be a single character")
+	}
+	if *limit < 0 {
+		return nil, fmt.Errorf("Limit must not be negative")
+	}
+
+	flagVals := listFlagVal{
+		prefixFlag:    string(*prefix),
+		delimiterFlag: delimiterRunes[0],
+		markerFlag:    string(*marker),
+		limitFlag:     int(*limit),
+		longFlag:      bool(*long),
+		recursiveFlag: bool(*recursive),
+	}
+
+	return &flagVals, nil
+}
+
+// ShowObjects lists the objects and pseudo-directories in a given container, one page at a time.
 func ShowObjects(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
 	writer.SetCurrentStage("Displaying objects")
 
 	container := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	flagVals, err := parseListFlags(args[4:])
+	if err != nil {
+		return "", err
+	}
+
+	opts := &swift.ObjectsOpts{
+		Prefix: flagVals.prefixFlag,
+		Marker: flagVals.markerFlag,
+	}
+	if !flagVals.recursiveFlag {
+		opts.Delimiter = flagVals.delimiterFlag
+	}
+
+	var (
+		listing     bytes.Buffer
+		numObjects  int
+		numDirs     int
+		totalBytes  int64
+		tableWriter = tabwriter.NewWriter(&listing, 0, 0, 2, ' ', 0)
+	)
+
+	if flagVals.longFlag {
+		fmt.Fprintln(tableWriter, "SIZE\tHASH\tCONTENT TYPE\tLAST MODIFIED\tNAME")
+	}
+
+	// Request one page at a time until the limit, or the end of the listing, is reached
+	for {
+		pageSize := listingPageSize
+		if flagVals.limitFlag > 0 && flagVals.limitFlag-numObjects-numDirs < pageSize {
+			pageSize = flagVals.limitFlag - numObjects - numDirs
+		}
+		opts.Limit = pageSize
+
+		page, err := connection.Objects(container, opts)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get objects: %s", err)
+		}
+
+		for _, object := range page {
+			if object.PseudoDirectory {
+				numDirs++
+			} else {
+				numObjects++
+				totalBytes += object.Bytes
+			}
+
+			if !flagVals.longFlag {
+				fmt.Fprintln(tableWriter, object.Name)
+			} else if object.PseudoDirectory {
+				fmt.Fprintf(tableWriter, "-\t-\tpseudo-directory\t-\t%s\n", object.Name)
+			} else {
+				fmt.Fprintf(tableWriter, "%s\t%s\t%s\t%s\t%s\n", w.FormatBytes(object.Bytes), object.Hash,
+					object.ContentType, object.LastModified.Format("2006-01-02 15:04:05"), object.Name)
+			}
+		}
+
+		// A pseudo-directory's own name is used as its marker, as Object Storage
+		// continues the listing after the objects within it
+		if len(page) > 0 {
+			opts.Marker = page[len(page)-1].Name
+		}
+		if len(page) < pageSize || numObjects+numDirs == flagVals.limitFlag {
+			break
+		}
+
+		writer.SetCurrentStage(fmt.Sprintf("Displaying objects (%d found)", numObjects+numDirs))
+	}
+	tableWriter.Flush()
+
+	result := fmt.Sprintf("\r%s%s\n\nObjects in container %s:\n", w.ClearLine, w.Green("OK"), container)
+	if numObjects+numDirs == 0 {
+		return result + "No objects found\n", nil
+	}
+
+	result += listing.String()
+	result += fmt.Sprintf("\n%d objects, %s", numObjects, w.FormatBytes(totalBytes))
+	if numDirs > 0 {
+		result += fmt.Sprintf(", %d pseudo-directories", numDirs)
+	}
+	result += "\n"
 
-	objects, err := dest.(*auth.SwiftDestination).SwiftConnection.ObjectNamesAll(container, nil)
+	if flagVals.limitFlag > 0 && numObjects+numDirs == flagVals.limitFlag {
+		result += fmt.Sprintf("More objects may follow, rerun with --marker %s to list them\n", opts.Marker)
+	}
+
+	return result, nil
+}
+
+// putFlagVal holds the flag values of the put-object subcommand.
+type putFlagVal struct {
+	nameFlag       string
+	thresholdFlag  int64
+	chunkSizeFlag  uint
+	numThreadsFlag uint
+	headers        swift.Headers
+}
+
+// parsePutFlags reads the flags and headers provided to the put-object subcommand.
+func parsePutFlags(args []string) (*putFlagVal, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	name := flagSet.String("n", "", "Name of the object (defaults to the file's name)")
+	threshold := flagSet.Int64("threshold", int64(MaxObjectSize), "Size, in bytes, above which the file is uploaded as an SLO")
+	chunkSize := flagSet.Uint("s", 0, "SLO chunk size, in bytes (defaults to 1GB or the threshold, whichever is smaller, or larger for very large files)")
+	threads := flagSet.Uint("t", uint(runtime.NumCPU()), "Maximum number of SLO uploader threads")
+	headerFlags := addHeaderFlags(flagSet)
+	expiryFlags := expiry.AddFlags(flagSet)
+
+	headers, err := parseWithHeaders(flagSet, args, nil)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get objects: %s", err)
+		return nil, err
+	}
+	headerFlags.apply(headers)
+
+	deleteAt, err := expiryFlags.DeleteAt()
+	if err != nil {
+		return nil, err
+	}
+	if deleteAt != "" {
+		headers[expiry.DeleteAtHeader] = deleteAt
+	}
+
+	// Streams upload up to the threshold before segmenting, so a threshold of 0 would upload an empty segment
+	if *threshold < 1 || *threshold > int64(MaxObjectSize) {
+		return nil, fmt.Errorf("Threshold must be between 1 and %d bytes", MaxObjectSize)
+	}
+
+	flagVals := putFlagVal{
+		nameFlag:       string(*name),
+		thresholdFlag:  int64(*threshold),
+		chunkSizeFlag:  uint(*chunkSize),
+		numThreadsFlag: uint(*threads),
+		headers:        headers,
 	}
 
-	return fmt.Sprintf("\r%s%s\n\nObjects in container %s: %v\n", w.ClearLine, w.Green("OK"), container, objects), nil
+	return &flagVals, nil
 }
 
-// PutObject uploads an object to Object Storage.
+// uploadStream uploads everything read from the source as an object with the given
+// headers, checking the object's ETag against the MD5 of what was read, and returns
+// that MD5 and the size read.
+func uploadStream(connection *swift.Connection, container, object string, source io.Reader,
+	headers swift.Headers) (string, int64, error) {
+	// Hash the source as it is uploaded, rather than reading it all beforehand
+	hasher := md5.New()
+
+	objectCreator, err := connection.ObjectCreate(container, object, false, "", "", headers)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to create object: %s", err)
+	}
+
+	size, err := io.Copy(objectCreator, io.TeeReader(source, hasher))
+	if err != nil {
+		objectCreator.CloseWithError(err)
+		return "", 0, fmt.Errorf("Failed to write object: %s", err)
+	}
+
+	err = objectCreator.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to close object writer: %s", err)
+	}
+
+	// Ensure the object was stored intact
+	responseHeaders, err := objectCreator.Headers()
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to get uploaded object's headers: %s", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	etag := strings.Trim(responseHeaders["Etag"], "\"")
+	if etag != hash {
+		return "", 0, fmt.Errorf("Uploaded object is corrupt: its ETag %s does not match the source's MD5 %s", etag, hash)
+	}
+
+	return hash, size, nil
+}
+
+// manifestSegment is a segment listed in the manifest uploaded to create an SLO.
+type manifestSegment struct {
+	Path      string `json:"path"`
+	Etag      string `json:"etag"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// putManifest creates an SLO with the given headers from segments that have already been uploaded.
+func putManifest(connection *swift.Connection, container, object string, segments []manifestSegment,
+	headers swift.Headers) error {
+	manifest, err := json.Marshal(segments)
+	if err != nil {
+		return fmt.Errorf("Failed to JSON encode manifest: %s", err)
+	}
+
+	_, _, err = connection.Call(connection.StorageUrl, swift.RequestOpts{
+		Container:  container,
+		ObjectName: object,
+		Operation:  "PUT",
+		Parameters: url.Values{"multipart-manifest": []string{"put"}},
+		Body:       bytes.NewReader(manifest),
+		Headers:    headers,
+		NoResponse: true,
+		OnReAuth: func() (string, error) {
+			return connection.StorageUrl, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to upload manifest: %s", err)
+	}
+
+	return nil
+}
+
+// streamBufferSize is how much of stdin put-object reads ahead, so that input
+// ending within it is uploaded as a single object without being segmented first.
+const streamBufferSize = 16 * 1000 * 1000
+
+// segmentName returns the name of an SLO segment uploaded by put-object from stdin.
+func segmentName(object string, index int) string {
+	return fmt.Sprintf("%s-segment-%04d", object, index)
+}
+
+// removeSegments deletes uploaded segments that will not be part of an SLO. This is
+// done on a best effort basis, as the upload has already failed or finished.
+func removeSegments(connection *swift.Connection, container string, segments []manifestSegment) {
+	for _, segment := range segments {
+		connection.ObjectDelete(container, strings.TrimPrefix(segment.Path, "/"+container+"/"))
+	}
+}
+
+// putStream uploads a stream of unknown length. Input that is seen to end within
+// the threshold is uploaded as a single object. Otherwise the input is uploaded
+// in segments, the first of the threshold's size, which become an SLO once every
+// segment has been uploaded; if the input turns out to end within the first
+// segment, it is copied to the object instead. The object's name is only written
+// once the whole input has been uploaded, and the segments are removed if the
+// upload fails. It returns true if an SLO was created.
+func putStream(connection *swift.Connection, writer *w.ConsoleWriter, container, object string, buffered *bufio.Reader,
+	threshold, segmentSize int64, headers swift.Headers) (bool, error) {
+	lookahead := threshold + 1
+	if lookahead > int64(buffered.Size()) {
+		lookahead = int64(buffered.Size())
+	}
+
+	_, err := buffered.Peek(int(lookahead))
+	if err == io.EOF {
+		_, _, err = uploadStream(connection, container, object, buffered, headers)
+		return false, err
+	} else if err != nil {
+		return false, fmt.Errorf("Failed to read input: %s", err)
+	}
+
+	// Segments expire along with the SLO
+	segmentHeaders := swift.Headers{}
+	if deleteAt, found := headers[expiry.DeleteAtHeader]; found {
+		segmentHeaders[expiry.DeleteAtHeader] = deleteAt
+	}
+
+	isSlo := false
+	segments := make([]manifestSegment, 0)
+	defer func() {
+		if !isSlo {
+			removeSegments(connection, container, segments)
+		}
+	}()
+
+	for index := 1; ; index++ {
+		if _, err = buffered.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			return false, fmt.Errorf("Failed to read input: %s", err)
+		}
+
+		if index > slo.MaxSegments {
+			return false, fmt.Errorf("Input is too large for an SLO of %d segments (use -s to upload larger segments)", slo.MaxSegments)
+		}
+
+		limit := segmentSize
+		if index == 1 {
+			limit = threshold
+		}
+
+		name := segmentName(object, index)
+		writer.SetCurrentStage(fmt.Sprintf("Uploading segment %d", index))
+		hash, size, err := uploadStream(connection, container, name, io.LimitReader(buffered, limit), segmentHeaders)
+		if err != nil {
+			return false, fmt.Errorf("Failed to upload segment %s: %s", name, err)
+		}
+
+		segments = append(segments, manifestSegment{Path: "/" + container + "/" + name, Etag: hash, SizeBytes: size})
+	}
+
+	if len(segments) == 1 {
+		writer.SetCurrentStage("Copying segment to object")
+		_, err = connection.ObjectCopy(container, segmentName(object, 1), container, object, headers)
+		if err != nil {
+			return false, fmt.Errorf("Failed to copy segment to object %s: %s", object, err)
+		}
+
+		return false, nil
+	}
+
+	writer.SetCurrentStage("Uploading manifest")
+	err = putManifest(connection, container, object, segments, headers)
+	if err != nil {
+		return false, err
+	}
+	isSlo = true
+
+	return true, nil
+}
+
+// PutObject uploads an object to Object Storage, streaming it from the source
+// file. Files larger than the threshold are uploaded as an SLO instead. A source
+// of - uploads from stdin, segmenting the input into an SLO if it passes the threshold.
+// The object's content type is detected from its name or contents unless one is given.
 func PutObject(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
 	writer.SetCurrentStage("Uploading object")
 
 	container := args[3]
 	path := args[4]
-	object := filepath.Base(path)
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
 
-	if len(args) == 7 && args[5] == "-n" {
-		object = args[6]
+	flagVals, err := parsePutFlags(args[5:])
+	if err != nil {
+		return "", err
 	}
 
-	data, err := getFileContents(path)
-	if err != nil {
-		return "", fmt.Errorf("Failed to get file contents at path %s: %s", path, err)
+	object := filepath.Base(path)
+	if flagVals.nameFlag != "" {
+		object = flagVals.nameFlag
 	}
 
-	hash := hashSource(data)
+	if path == "-" {
+		if flagVals.nameFlag == "" {
+			return "", fmt.Errorf("Please provide an object name with -n when uploading from stdin")
+		}
+
+		segmentSize := int64(flagVals.chunkSizeFlag)
+		if segmentSize == 0 {
+			segmentSize = slo.DefaultChunkSize
+		}
+
+		source := bufio.NewReaderSize(os.Stdin, streamBufferSize)
+		if flagVals.headers["Content-Type"] == "" {
+			flagVals.headers["Content-Type"] = detectContentType(object, source)
+		}
+
+		isSlo, err := putStream(connection, writer, container, object, source, flagVals.thresholdFlag, segmentSize,
+			flagVals.headers)
+		if err != nil {
+			return "", err
+		}
+
+		if isSlo {
+			return fmt.Sprintf("\r%s%s\n\nUploaded object %s to container %s as an SLO\n", w.ClearLine, w.Green("OK"), object, container), nil
+		}
 
-	// ADD SUPPORT FOR HEADERS
-	objectCreator, err := dest.(*auth.SwiftDestination).SwiftConnection.ObjectCreate(container, object, true, hash, "", nil)
+		return fmt.Sprintf("\r%s%s\n\nUploaded object %s to container %s\n", w.ClearLine, w.Green("OK"), object, container), nil
+	}
+
+	file, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("Failed to create object: %s", err)
+		return "", fmt.Errorf("Failed to open source file: %s", err)
 	}
+	defer file.Close()
 
-	_, err = objectCreator.Write(data)
+	info, err := file.Stat()
 	if err != nil {
-		return "", fmt.Errorf("Failed to write object: %s", err)
+		return "", fmt.Errorf("Failed to get source file info: %s", err)
 	}
 
-	err = objectCreator.Close()
+	source := bufio.NewReader(file)
+	if flagVals.headers["Content-Type"] == "" {
+		flagVals.headers["Content-Type"] = detectContentType(object, source)
+	}
+
+	if info.Size() > flagVals.thresholdFlag {
+		writer.SetCurrentStage("Uploading object as an SLO")
+
+		chunkSize := flagVals.chunkSizeFlag
+		if chunkSize == 0 {
+			chunkSize = slo.ChunkSizeFor(info.Size(), flagVals.thresholdFlag)
+		}
+
+		// Sniffing the content type may have read from the file, so it is rewound for the uploader
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read source file: %s", err)
+		}
+
+		err = slo.UploadFile(dest, writer, container, object, file, chunkSize, flagVals.numThreadsFlag, false, ioutil.Discard)
+		if err != nil {
+			return "", err
+		}
+
+		// The uploader creates the manifest and segments without headers, so they are added afterwards
+		if deleteAt, found := flagVals.headers[expiry.DeleteAtHeader]; found {
+			err = slo.ExpireSegments(connection, container, object, deleteAt)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		err = connection.ObjectUpdate(container, object, flagVals.headers)
+		if err != nil {
+			return "", fmt.Errorf("Failed to set headers of object %s: %s", object, err)
+		}
+
+		return fmt.Sprintf("\r%s%s\n%s\nUploaded object %s to container %s as an SLO\n", w.ClearLine, w.Green("OK"), w.ClearLine, object, container), nil
+	}
+
+	progress := w.NewTransferProgress(info.Size())
+	writer.SetStatus(progress)
+
+	_, _, err = uploadStream(connection, container, object, progress.Reader(source), flagVals.headers)
 	if err != nil {
-		return "", fmt.Errorf("Failed to close object writer: %s", err)
+		return "", err
 	}
 
-	return fmt.Sprintf("\r%s%s\n\nUploaded object %s to container %s\n", w.ClearLine, w.Green("OK"), object, container), nil
+	return fmt.Sprintf("\r%s%s\n%s\nUploaded object %s to container %s\n", w.ClearLine, w.Green("OK"), w.ClearLine, object, container), nil
 }
 
 // CopyObject copies an object from one container to another
@@ -109,26 +558,324 @@ func CopyObject(dest auth.Destination, writer *w.ConsoleWriter, args []string) (
 	return fmt.Sprintf("\r%s%s\n\nCopied object %s to container %s\n", w.ClearLine, w.Green("OK"), object, newContainer), nil
 }
 
-// GetObject downloads an object from object storage.
+// maxDownloadAttempts is how many times get-object resumes an interrupted download before giving up.
+const maxDownloadAttempts = 3
+
+// partialSuffix ends the name of the file a download is written to until it is complete and verified.
+const partialSuffix = ".part"
+
+// etagPattern matches the MD5 ETags that partial files are named after.
+var etagPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// byteRange matches the byte ranges accepted by get-object's range flag.
+var byteRange = regexp.MustCompile(`^(\d+-\d*|-\d+)$`)
+
+// parseGetFlags reads the byte range, if any, from the flags provided to the get-object subcommand.
+func parseGetFlags(args []string) (string, error) {
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	rangeFlag := flagSet.String("range", "", "Only download the given bytes, such as 0-1023, 1024- or -512")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	byteRangeVal := strings.TrimPrefix(*rangeFlag, "bytes=")
+	if byteRangeVal != "" && !byteRange.MatchString(byteRangeVal) {
+		return "", fmt.Errorf("Invalid range '%s' (must be first-last, first- or -suffix_length)", *rangeFlag)
+	}
+
+	return byteRangeVal, nil
+}
+
+// resumeDownload appends the rest of an object to a partially downloaded file.
+func resumeDownload(connection *swift.Connection, progress *w.TransferProgress, container, objectName, partPath string, size int64) error {
+	part, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("Failed to open/create partial file: %s", err)
+	}
+	defer part.Close()
+
+	offset, err := part.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("Failed to read partial file: %s", err)
+	}
+
+	if offset == size {
+		return nil
+	} else if offset > size {
+		offset = 0
+	}
+
+	requestHeaders := swift.Headers{}
+	if offset > 0 {
+		requestHeaders["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	source, headers, err := connection.ObjectOpen(container, objectName, false, requestHeaders)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	// A server that ignores the range sends the whole object
+	if headers["Content-Range"] == "" {
+		offset = 0
+	}
+
+	err = part.Truncate(offset)
+	if err == nil {
+		_, err = part.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to prepare partial file: %s", err)
+	}
+
+	_, err = io.Copy(part, progress.Reader(source))
+
+	return err
+}
+
+// verifyDownload checks a downloaded file against the object's ETag, which for
+// an SLO is the MD5 of its segments' MD5s. DLOs have no ETag to compare with.
+func verifyDownload(connection *swift.Connection, container, objectName, filePath string, headers swift.Headers) error {
+	if headers["X-Object-Manifest"] != "" {
+		return nil
+	}
+
+	etag := strings.Trim(headers["Etag"], "\"")
+
+	hash, err := HashFile(filePath)
+	if err != nil || hash == etag {
+		return err
+	}
+
+	// The ETag of an SLO covers its segments' MD5s, which are listed in its manifest
+	if slo.IsSlo(headers) {
+		segments, err := slo.GetManifest(connection, container, objectName)
+		if err != nil {
+			return err
+		}
+		hash, err = HashFileSegments(filePath, segments)
+		if err != nil || hash == etag {
+			return err
+		}
+	}
+
+	return fmt.Errorf("Downloaded file is corrupt: its MD5 %s does not match the object's ETag %s", hash, etag)
+}
+
+// getRange downloads part of an object, writing it to a partial file that
+// replaces the destination when done. Servers that ignore the range, and send
+// the whole object instead, are refused.
+func getRange(connection *swift.Connection, container, objectName, destinationPath, byteRangeVal string) error {
+	source, headers, err := connection.ObjectOpen(container, objectName, false, swift.Headers{"Range": "bytes=" + byteRangeVal})
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	if headers["Content-Range"] == "" {
+		return fmt.Errorf("Object Storage did not return a partial response for the range")
+	}
+
+	partPath := PartialPath(destinationPath, headers["Etag"])
+	err = RemoveStalePartials(map[string]string{destinationPath: partPath})
+	if err != nil {
+		return err
+	}
+
+	part, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create partial file: %s", err)
+	}
+	defer os.Remove(partPath)
+
+	_, err = io.Copy(part, source)
+	if closeErr := part.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(partPath, destinationPath)
+}
+
+// getToStdout streams an object, or part of it, to stdout. Whole objects are
+// checked against their ETag once they have been written.
+func getToStdout(connection *swift.Connection, container, objectName, byteRangeVal string) error {
+	requestHeaders := swift.Headers{}
+	if byteRangeVal != "" {
+		requestHeaders["Range"] = "bytes=" + byteRangeVal
+	}
+
+	source, _, err := connection.ObjectOpen(container, objectName, byteRangeVal == "", requestHeaders)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(os.Stdout, source)
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// PartialPath returns the path of the partial file that a download of the object
+// with the given ETag to the destination is written to until it is complete.
+func PartialPath(destinationPath, etag string) string {
+	return fmt.Sprintf("%s.%s%s", destinationPath, strings.Trim(etag, "\""), partialSuffix)
+}
+
+// partialDestination returns the name of the destination that a partial file, named
+// after an object's ETag, belongs to, and false if the name is not a partial file's.
+func partialDestination(name string) (string, bool) {
+	if !strings.HasSuffix(name, partialSuffix) {
+		return "", false
+	}
+
+	withoutSuffix := strings.TrimSuffix(name, partialSuffix)
+	dot := strings.LastIndex(withoutSuffix, ".")
+	if dot < 1 || !etagPattern.MatchString(withoutSuffix[dot+1:]) {
+		return "", false
+	}
+
+	return withoutSuffix[:dot], true
+}
+
+// RemoveStalePartials removes the partial files left by interrupted downloads of
+// other versions of objects to the given destinations, which can never be resumed.
+// It is given the current partial path of each destination, which is kept, and
+// reads each directory once however many destinations are in it.
+func RemoveStalePartials(partPaths map[string]string) error {
+	dirs := make(map[string]bool)
+	for destinationPath := range partPaths {
+		dirs[filepath.Dir(destinationPath)] = true
+	}
+
+	for dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Failed to read directory %s: %s", dir, err)
+		}
+
+		for _, entry := range entries {
+			destination, isPartial := partialDestination(entry.Name())
+			if entry.IsDir() || !isPartial {
+				continue
+			}
+
+			stalePath := filepath.Join(dir, entry.Name())
+			partPath, found := partPaths[filepath.Join(dir, destination)]
+			if !found || stalePath == partPath {
+				continue
+			}
+
+			err = os.Remove(stalePath)
+			if err != nil {
+				return fmt.Errorf("Failed to remove stale partial file: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DownloadFile downloads an object to a partial file, named after its ETag so
+// that an interrupted download of the same object can be resumed, which replaces
+// the destination once it has been verified. Any bytes already downloaded are
+// added to the progress. Callers remove stale partial files beforehand.
+func DownloadFile(connection *swift.Connection, progress *w.TransferProgress, container, objectName, destinationPath string,
+	size int64, headers swift.Headers) error {
+	partPath := PartialPath(destinationPath, headers["Etag"])
+
+	if info, err := os.Stat(partPath); err == nil && info.Size() <= size {
+		progress.Add(info.Size())
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxDownloadAttempts; attempt++ {
+		err = resumeDownload(connection, progress, container, objectName, partPath, size)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to download object (rerun to resume the download): %s", err)
+	}
+
+	err = verifyDownload(connection, container, objectName, partPath, headers)
+	if err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("Failed to verify object: %s", err)
+	}
+
+	err = os.Rename(partPath, destinationPath)
+	if err != nil {
+		return fmt.Errorf("Failed to move download to %s: %s", destinationPath, err)
+	}
+
+	return nil
+}
+
+// GetObject downloads an object from object storage, resuming an earlier
+// interrupted download of the same object. A destination of - writes the object
+// to stdout instead.
 func GetObject(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
 	writer.SetCurrentStage("Downloading object")
 
 	container := args[3]
 	objectName := args[4]
 	destinationPath := args[5]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
 
-	object, err := os.OpenFile(destinationPath, os.O_CREATE|os.O_WRONLY, 0666)
+	byteRangeVal, err := parseGetFlags(args[6:])
 	if err != nil {
-		return "", fmt.Errorf("Failed to open/create object file: %s", err)
+		return "", err
+	}
+
+	if destinationPath == "-" {
+		err = getToStdout(connection, container, objectName, byteRangeVal)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get object %s: %s", objectName, err)
+		}
+
+		return fmt.Sprintf("\r%s%s\n\nDownloaded object %s to stdout\n", w.ClearLine, w.Green("OK"), objectName), nil
+	}
+
+	if byteRangeVal != "" {
+		err = getRange(connection, container, objectName, destinationPath, byteRangeVal)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get range %s of object %s: %s", byteRangeVal, objectName, err)
+		}
+
+		return fmt.Sprintf("\r%s%s\n\nDownloaded bytes %s of object %s to %s\n", w.ClearLine, w.Green("OK"), byteRangeVal, objectName, destinationPath), nil
 	}
-	defer object.Close()
 
-	_, err = dest.(*auth.SwiftDestination).SwiftConnection.ObjectGet(container, objectName, object, true, nil)
+	objectInfo, headers, err := connection.Object(container, objectName)
 	if err != nil {
 		return "", fmt.Errorf("Failed to get object %s: %s", objectName, err)
 	}
 
-	return fmt.Sprintf("\r%s%s\n\nDownloaded object %s to %s\n", w.ClearLine, w.Green("OK"), objectName, destinationPath), nil
+	err = RemoveStalePartials(map[string]string{destinationPath: PartialPath(destinationPath, headers["Etag"])})
+	if err != nil {
+		return "", err
+	}
+
+	progress := w.NewTransferProgress(objectInfo.Bytes)
+	writer.SetStatus(progress)
+
+	err = DownloadFile(connection, progress, container, objectName, destinationPath, objectInfo.Bytes, headers)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get object %s: %s", objectName, err)
+	}
+
+	return fmt.Sprintf("\r%s%s\n%s\nDownloaded object %s to %s\n", w.ClearLine, w.Green("OK"), w.ClearLine, objectName, destinationPath), nil
 }
 
 // RenameObject renames a given object.
@@ -218,36 +965,43 @@ func deleteLargeObject(dest auth.Destination, container, objectName string) erro
 	return nil
 }
 
-// getFileContents returns the raw contents of a file.
-func getFileContents(sourcePath string) ([]byte, error) {
-	file, err := os.Open(sourcePath)
+// HashFile returns the MD5 of a file's contents, reading it a piece at a time.
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to open source file: %s", err)
+		return "", fmt.Errorf("Failed to open %s: %s", filePath, err)
 	}
 	defer file.Close()
 
-	info, err := file.Stat()
+	hasher := md5.New()
+	_, err = io.Copy(hasher, file)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get source file info: %s", err)
+		return "", fmt.Errorf("Failed to read %s: %s", filePath, err)
 	}
 
-	if uint(info.Size()) > maxObjectSize {
-		return nil, fmt.Errorf("%s is too large to upload as a single object (max 5GB)", info.Name())
-	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
 
-	data := make([]byte, info.Size())
-	_, err = file.Read(data)
+// HashFileSegments returns the ETag an SLO would have if its segments held the
+// file's contents: the MD5 of the concatenated MD5s of each segment.
+func HashFileSegments(filePath string, segments []slo.Segment) (string, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read source file: %s", err)
+		return "", fmt.Errorf("Failed to open %s: %s", filePath, err)
 	}
+	defer file.Close()
 
-	return data, nil
-}
+	segmentHashes := ""
+	for _, segment := range segments {
+		hasher := md5.New()
+		_, err = io.CopyN(hasher, file, segment.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read %s: %s", filePath, err)
+		}
+		segmentHashes += hex.EncodeToString(hasher.Sum(nil))
+	}
 
-// hashSource hashes raw file contents.
-func hashSource(sourceData []byte) string {
-	hashBytes := md5.Sum(sourceData)
-	hash := hex.EncodeToString(hashBytes[:])
+	hashBytes := md5.Sum([]byte(segmentHashes))
 
-	return hash
+	return hex.EncodeToString(hashBytes[:]), nil
 }
@@ -0,0 +1,79 @@
+package object
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPartialDestination(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		isPartial   bool
+	}{
+		{"report.csv.d41d8cd98f00b204e9800998ecf8427e.part", "report.csv", true},
+		{"report.csv.backup.d41d8cd98f00b204e9800998ecf8427e.part", "report.csv.backup", true},
+		{"report.d41d8cd98f00b204e9800998ecf8427e.part", "report", true},
+		{"report.csv.part", "", false},
+		{"report.csv", "", false},
+		{"report.csv.d41d8cd98f00b204e9800998ecf8427e", "", false},
+		{".d41d8cd98f00b204e9800998ecf8427e.part", "", false},
+		{"report.csv.D41D8CD98F00B204E9800998ECF8427E.part", "", false},
+	}
+
+	for _, test := range tests {
+		destination, isPartial := partialDestination(test.name)
+		if destination != test.destination || isPartial != test.isPartial {
+			t.Errorf("partialDestination(%q) = %q, %t, want %q, %t", test.name, destination, isPartial,
+				test.destination, test.isPartial)
+		}
+	}
+}
+
+func TestRemoveStalePartials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const (
+		oldEtag     = "d41d8cd98f00b204e9800998ecf8427e"
+		currentEtag = "9e107d9d372bb6826bd81d3542a419d6"
+	)
+
+	report := filepath.Join(dir, "report.csv")
+	files := map[string]bool{
+		// Partial files of the current versions are kept for resuming
+		PartialPath(report, currentEtag): true,
+		PartialPath(report, oldEtag):     false,
+		// Partial files of destinations that are not being downloaded are kept
+		PartialPath(filepath.Join(dir, "report.csv.backup"), oldEtag): true,
+		filepath.Join(dir, "report.csv.part"):                         true,
+		filepath.Join(dir, "notes.txt"):                               true,
+	}
+	for path := range files {
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = RemoveStalePartials(map[string]string{
+		report:                        PartialPath(report, currentEtag),
+		filepath.Join(dir, "new.txt"): PartialPath(filepath.Join(dir, "new.txt"), currentEtag),
+	})
+	if err != nil {
+		t.Fatalf("RemoveStalePartials returned error: %s", err)
+	}
+
+	for path, kept := range files {
+		_, err := os.Stat(path)
+		if kept && err != nil {
+			t.Errorf("%s was removed", filepath.Base(path))
+		} else if !kept && !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", filepath.Base(path))
+		}
+	}
+}
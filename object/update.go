@@ -0,0 +1,212 @@
+package object
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ibmjstart/cf-object-storage/expiry"
+	"github.com/ibmjstart/cf-object-storage/slo"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// shortHeaders define shortcuts for header input.
+var shortHeaders = map[string]string{
+	"-attachment": "Content-Disposition:attachment",
+	"-inline":     "Content-Disposition:inline",
+	"-no-cache":   "Cache-Control:no-cache",
+}
+
+// removePrefix begins the names of headers that remove the header named by the rest of the name.
+const removePrefix = "X-Remove-"
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// updateFlagVal holds the flag values of the update-object subcommand.
+type updateFlagVal struct {
+	prefixFlag     string
+	numThreadsFlag int
+	headers        swift.Headers
+}
+
+// parseUpdateFlags reads the flags and headers provided to the update-object subcommand.
+func parseUpdateFlags(args []string) (*updateFlagVal, error) {
+	var (
+		flagVals       updateFlagVal
+		removeMetaFlag stringList
+	)
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	flagSet.StringVar(&flagVals.prefixFlag, "prefix", "", "Update every object beginning with this prefix")
+	flagSet.IntVar(&flagVals.numThreadsFlag, "t", runtime.NumCPU(), "Number of objects to update at once")
+	flagSet.Var(&removeMetaFlag, "remove-meta", "Metadata key to remove from the object")
+	headerFlags := addHeaderFlags(flagSet)
+	expiryFlags := expiry.AddFlags(flagSet)
+
+	headers, err := parseWithHeaders(flagSet, args, shortHeaders)
+	if err != nil {
+		return nil, err
+	}
+	headerFlags.apply(headers)
+
+	deleteAt, err := expiryFlags.DeleteAt()
+	if err != nil {
+		return nil, err
+	}
+	if deleteAt != "" {
+		headers[expiry.DeleteAtHeader] = deleteAt
+	}
+
+	for _, key := range removeMetaFlag {
+		headers[http.CanonicalHeaderKey(removePrefix+"Object-Meta-"+key)] = "1"
+	}
+
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("Please provide the headers or metadata to update")
+	}
+	if flagVals.numThreadsFlag < 1 {
+		return nil, fmt.Errorf("Number of threads must be at least 1")
+	}
+
+	flagVals.headers = headers
+
+	return &flagVals, nil
+}
+
+// updateObject POSTs the given headers to an object. As a POST replaces all of an
+// object's metadata, the object's current metadata is merged with the headers,
+// leaving out anything they remove.
+func updateObject(connection *swift.Connection, container, objectName string, headers swift.Headers) error {
+	_, current, err := connection.Object(container, objectName)
+	if err != nil {
+		return fmt.Errorf("Failed to get object %s: %s", objectName, err)
+	}
+
+	merged := swift.Headers{}
+	for name, value := range current {
+		if strings.HasPrefix(name, metadataPrefix) {
+			merged[name] = value
+		}
+	}
+	for _, name := range expiry.PreservedHeaders {
+		if value, found := current[name]; found {
+			merged[name] = value
+		}
+	}
+
+	for name, value := range headers {
+		merged[name] = value
+		if strings.HasPrefix(name, removePrefix) {
+			delete(merged, "X-"+strings.TrimPrefix(name, removePrefix))
+		}
+	}
+
+	// The segments of an SLO expire along with it
+	if deleteAt, found := headers[expiry.DeleteAtHeader]; found && strings.EqualFold(current["X-Static-Large-Object"], "true") {
+		err = slo.ExpireSegments(connection, container, objectName, deleteAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = connection.ObjectUpdate(container, objectName, merged)
+	if err != nil {
+		return fmt.Errorf("Failed to update object %s: %s", objectName, err)
+	}
+
+	return nil
+}
+
+// UpdateObject updates the metadata and headers of an object, or of every object
+// beginning with a prefix, without re-uploading it.
+func UpdateObject(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Updating object")
+
+	container := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	objectName := ""
+	flagArgs := args[4:]
+	if len(flagArgs) > 0 && !strings.HasPrefix(flagArgs[0], "-") {
+		objectName = flagArgs[0]
+		flagArgs = flagArgs[1:]
+	}
+
+	flagVals, err := parseUpdateFlags(flagArgs)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case objectName != "" && flagVals.prefixFlag != "":
+		return "", fmt.Errorf("Please provide either an object_name or -prefix, not both")
+	case objectName != "":
+		err = updateObject(connection, container, objectName, flagVals.headers)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("\r%s%s\n\nUpdated object %s in container %s\n", w.ClearLine, w.Green("OK"), objectName, container), nil
+	case flagVals.prefixFlag == "":
+		return "", fmt.Errorf("Please provide an object_name or -prefix")
+	}
+
+	writer.SetCurrentStage("Finding objects to update")
+	names, err := connection.ObjectNamesAll(container, &swift.ObjectsOpts{Prefix: flagVals.prefixFlag})
+	if err != nil {
+		return "", fmt.Errorf("Failed to get objects: %s", err)
+	}
+
+	writer.SetCurrentStage(fmt.Sprintf("Updating %d objects", len(names)))
+
+	// Update the objects using a pool of workers
+	updateErrors := make([]error, len(names))
+	jobs := make(chan int)
+	var workers sync.WaitGroup
+	for t := 0; t < flagVals.numThreadsFlag; t++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				updateErrors[i] = updateObject(connection, container, names[i], flagVals.headers)
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	workers.Wait()
+
+	failures := ""
+	failed := 0
+	for _, updateErr := range updateErrors {
+		if updateErr != nil {
+			failures += fmt.Sprintf("\t%s\n", updateErr)
+			failed++
+		}
+	}
+
+	summary := fmt.Sprintf("Updated %d objects beginning with %s in container %s", len(names)-failed, flagVals.prefixFlag, container)
+	if failed > 0 {
+		return "", fmt.Errorf("%s, failed %d objects:\n%s", summary, failed, failures)
+	}
+
+	return fmt.Sprintf("\r%s%s\n\n%s\n", w.ClearLine, w.Green("OK"), summary), nil
+}
@@ -1,18 +1,29 @@
 package slo
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"runtime"
+	"strings"
 
+	"github.com/ibmjstart/cf-object-storage/expiry"
 	w "github.com/ibmjstart/cf-object-storage/writer"
 	sg "github.com/ibmjstart/swiftlygo"
 	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
 )
 
+// DefaultChunkSize is the size, in bytes, of the chunks uploaded by default.
+const DefaultChunkSize = 1 * 1000 * 1000 * 1000
+
+// MaxSegments is the largest number of segments an SLO manifest may list.
+const MaxSegments = 1000
+
 // argVal holds the parsed argument values.
 type argVal struct {
 	SloContainer string
@@ -27,6 +38,7 @@ type flagVal struct {
 	outputFileFlag  string
 	chunkSizeFlag   int
 	numThreadsFlag  int
+	deleteAtFlag    string
 }
 
 // parseArgs parses the arguments provided to make-slo.
@@ -40,8 +52,9 @@ func parseArgs(args []string) (*argVal, error) {
 	// Define flags and set defaults
 	missing := flagSet.Bool("m", false, "Only upload missing chunks")
 	output := flagSet.String("o", "", "Destination for log data")
-	chunkSize := flagSet.Int("s", 1*1000*1000*1000, "Chunk size, in bytes (defaults to create 1GB chunks)")
+	chunkSize := flagSet.Int("s", DefaultChunkSize, "Chunk size, in bytes (defaults to create 1GB chunks)")
 	threads := flagSet.Int("t", runtime.NumCPU(), "Maximum number of uploader threads (defaults to the available number of CPUs")
+	expiryFlags := expiry.AddFlags(flagSet)
 
 	// Parse optional flags if they have been provided
 	if len(args) > 3 {
@@ -51,11 +64,17 @@ func parseArgs(args []string) (*argVal, error) {
 		}
 	}
 
+	deleteAt, err := expiryFlags.DeleteAt()
+	if err != nil {
+		return nil, err
+	}
+
 	flagVals := flagVal{
 		onlyMissingFlag: bool(*missing),
 		outputFileFlag:  string(*output),
 		chunkSizeFlag:   int(*chunkSize),
 		numThreadsFlag:  int(*threads),
+		deleteAtFlag:    deleteAt,
 	}
 
 	argVals := argVal{
@@ -68,11 +87,56 @@ func parseArgs(args []string) (*argVal, error) {
 	return &argVals, nil
 }
 
+// UploadFile uploads an open file as an SLO in chunks of the given size,
+// providing the console writer with the upload's status.
+func UploadFile(dest auth.Destination, writer *w.ConsoleWriter, container, name string, file *os.File,
+	chunkSize, numThreads uint, onlyMissing bool, output io.Writer) error {
+	// Create SLO uploader
+	uploader, err := sg.NewSloUploader(dest, chunkSize, container, name, file, numThreads, onlyMissing, output)
+	if err != nil {
+		return fmt.Errorf("Failed to create SLO uploader: %s", err)
+	}
+
+	// Provide the console writer with upload status
+	writer.SetStatus(uploader.Status)
+
+	// Upload SLO
+	err = uploader.Upload()
+	if err != nil {
+		return fmt.Errorf("Failed to upload SLO: %s", err)
+	}
+
+	return nil
+}
+
+// ChunkSizeFor returns a chunk size suited to uploading a file of the given size:
+// 1GB or the given maximum, whichever is smaller, so that a file just over a low
+// threshold is still split into several segments. Very large files use larger
+// chunks, even past the maximum, to keep within Object Storage's limit on the
+// number of segments in an SLO. Files smaller than a chunk are a single chunk.
+func ChunkSizeFor(size, maxChunkSize int64) uint {
+	chunkSize := int64(DefaultChunkSize)
+	if maxChunkSize > 0 && maxChunkSize < chunkSize {
+		chunkSize = maxChunkSize
+	}
+	if size/chunkSize >= MaxSegments {
+		chunkSize = (size + MaxSegments - 1) / MaxSegments
+	}
+	if size < chunkSize {
+		chunkSize = size
+	}
+
+	return uint(chunkSize)
+}
+
 // MakeSlo uploads the given file as an SLO to Object Storage.
 func MakeSlo(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
 	writer.SetCurrentStage("Preparing SLO")
 
 	argVals, err := parseArgs(args[3:])
+	if err != nil {
+		return "", err
+	}
 
 	// Verify source file exists
 	file, err := os.Open(argVals.source)
@@ -104,22 +168,108 @@ func MakeSlo(dest auth.Destination, writer *w.ConsoleWriter, args []string) (str
 		}
 	}
 
-	// Create SLO uploader without output file
-	uploader, err := sg.NewSloUploader(dest, uint(argVals.flagVals.chunkSizeFlag),
-		argVals.SloContainer, argVals.SloName, file, uint(argVals.flagVals.numThreadsFlag),
-		argVals.flagVals.onlyMissingFlag, output)
+	err = UploadFile(dest, writer, argVals.SloContainer, argVals.SloName, file, uint(argVals.flagVals.chunkSizeFlag),
+		uint(argVals.flagVals.numThreadsFlag), argVals.flagVals.onlyMissingFlag, output)
 	if err != nil {
-		return "", fmt.Errorf("Failed to create SLO uploader: %s", err)
+		return "", err
 	}
 
-	// Provide the console writer with upload status
-	writer.SetStatus(uploader.Status)
+	if argVals.flagVals.deleteAtFlag != "" {
+		writer.SetCurrentStage("Scheduling SLO expiry")
 
-	// Upload SLO
-	err = uploader.Upload()
-	if err != nil {
-		return "", fmt.Errorf("Failed to upload SLO: %s", err)
+		connection := dest.(*auth.SwiftDestination).SwiftConnection
+		err = ExpireSegments(connection, argVals.SloContainer, argVals.SloName, argVals.flagVals.deleteAtFlag)
+		if err != nil {
+			return "", err
+		}
+
+		err = expiry.Schedule(connection, argVals.SloContainer, argVals.SloName, argVals.flagVals.deleteAtFlag)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	return fmt.Sprintf("\r%s%s\n%s\nSuccessfully created SLO %s in container %s\n", w.ClearLine, w.Green("OK"), w.ClearLine, w.Cyan(argVals.SloName), w.Cyan(argVals.SloContainer)), nil
 }
+
+// Segment is a segment listed in an SLO's manifest.
+type Segment struct {
+	Name  string `json:"name"`
+	Hash  string `json:"hash"`
+	Bytes int64  `json:"bytes"`
+}
+
+// IsSlo returns true if an object's headers show that it is an SLO.
+func IsSlo(headers swift.Headers) bool {
+	return strings.EqualFold(headers["X-Static-Large-Object"], "true")
+}
+
+// GetSegments returns the segments of an SLO, or nil if the object is not an SLO.
+// The object is checked with a HEAD request first, as asking for the manifest of
+// an object that is not an SLO returns the whole object instead.
+func GetSegments(connection *swift.Connection, container, objectName string) ([]Segment, error) {
+	_, headers, err := connection.Object(container, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get info of %s: %s", objectName, err)
+	}
+
+	if !IsSlo(headers) {
+		return nil, nil
+	}
+
+	return GetManifest(connection, container, objectName)
+}
+
+// GetManifest returns the segments listed in the manifest of an object already known to be an SLO.
+func GetManifest(connection *swift.Connection, container, objectName string) ([]Segment, error) {
+	response, headers, err := connection.Call(connection.StorageUrl, swift.RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  "GET",
+		Parameters: url.Values{"multipart-manifest": []string{"get"}},
+		OnReAuth: func() (string, error) {
+			return connection.StorageUrl, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get manifest of %s: %s", objectName, err)
+	}
+	defer response.Body.Close()
+
+	// The object may have been replaced since it was found to be an SLO
+	if !IsSlo(headers) {
+		return nil, fmt.Errorf("%s is no longer an SLO", objectName)
+	}
+
+	var segments []Segment
+	err = json.NewDecoder(response.Body).Decode(&segments)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshall manifest of %s: %s", objectName, err)
+	}
+
+	return segments, nil
+}
+
+// ExpireSegments schedules the deletion of an SLO's segments at the given Unix
+// time, so that they do not outlive the manifest that lists them.
+func ExpireSegments(connection *swift.Connection, container, name, deleteAt string) error {
+	segments, err := GetSegments(connection, container, name)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		// Segments are named by their path, /container/object
+		segmentPath := strings.SplitN(strings.TrimPrefix(segment.Name, "/"), "/", 2)
+		if len(segmentPath) != 2 {
+			return fmt.Errorf("Invalid segment name '%s' in manifest of %s", segment.Name, name)
+		}
+
+		err = expiry.Schedule(connection, segmentPath[0], segmentPath[1], deleteAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package slo
+
+import "testing"
+
+func TestChunkSizeFor(t *testing.T) {
+	tests := []struct {
+		size         int64
+		maxChunkSize int64
+		want         uint
+	}{
+		{0, DefaultChunkSize, 0},
+		{1, DefaultChunkSize, 1},
+		{DefaultChunkSize - 1, DefaultChunkSize, DefaultChunkSize - 1},
+		{DefaultChunkSize, DefaultChunkSize, DefaultChunkSize},
+		{10 * DefaultChunkSize, DefaultChunkSize, DefaultChunkSize},
+		{10 * DefaultChunkSize, 5 * DefaultChunkSize, DefaultChunkSize},
+		{(MaxSegments - 1) * DefaultChunkSize, DefaultChunkSize, DefaultChunkSize},
+		// Files just over a low threshold are split into chunks of the threshold's size
+		{1000001, 1000000, 1000000},
+		{3 * 1000000, 1000000, 1000000},
+		{500, 1000000, 500},
+		// Larger files use larger chunks to stay within the segment limit
+		{MaxSegments * DefaultChunkSize, DefaultChunkSize, DefaultChunkSize},
+		{MaxSegments*DefaultChunkSize + 1, DefaultChunkSize, DefaultChunkSize + 1},
+		{2 * MaxSegments * DefaultChunkSize, DefaultChunkSize, 2 * DefaultChunkSize},
+		{MaxSegments*1000000 + 1, 1000000, 1000001},
+	}
+
+	for _, test := range tests {
+		got := ChunkSizeFor(test.size, test.maxChunkSize)
+		if got != test.want {
+			t.Errorf("ChunkSizeFor(%d, %d) = %d, want %d", test.size, test.maxChunkSize, got, test.want)
+		}
+
+		if got > 0 {
+			if segments := (test.size + int64(got) - 1) / int64(got); segments > MaxSegments {
+				t.Errorf("ChunkSizeFor(%d, %d) = %d needs %d segments, more than %d", test.size, test.maxChunkSize,
+					got, segments, MaxSegments)
+			}
+		}
+	}
+}
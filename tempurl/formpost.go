@@ -0,0 +1,197 @@
+package tempurl
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ibmjstart/cf-object-storage/expiry"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ibmjstart/swiftlygo/auth"
+)
+
+// defaultMaxFileSize is the largest file a form accepts by default: the largest object Object Storage allows.
+const defaultMaxFileSize = 1000 * 1000 * 1000 * 5
+
+// formTemplate is the HTML form written with the html flag. The signed fields
+// must come before the file input, as Object Storage reads them in order.
+var formTemplate = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Upload to {{.Container}}</title>
+</head>
+<body>
+  <form action="{{.Action}}" method="POST" enctype="multipart/form-data">
+{{- range .Fields}}
+    <input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{- end}}
+    <input type="file" name="file"{{if gt .MaxFileCount 1}} multiple{{end}}>
+    <input type="submit" value="Upload">
+  </form>
+</body>
+</html>
+`))
+
+// formField is a signed field of a form.
+type formField struct {
+	Name  string
+	Value string
+}
+
+// form holds what is needed to render the HTML form.
+type form struct {
+	Container    string
+	Action       string
+	Fields       []formField
+	MaxFileCount int
+}
+
+// formPostFlagVal holds the flag values of the form-post subcommand.
+type formPostFlagVal struct {
+	prefixFlag       string
+	redirectFlag     string
+	maxFileSizeFlag  int64
+	maxFileCountFlag int
+	lifetimeFlag     time.Duration
+	digestFlag       string
+	signKeyFlag      string
+	htmlFlag         string
+}
+
+// parseFormPostFlags reads the flags provided to the form-post subcommand.
+func parseFormPostFlags(args []string) (*formPostFlagVal, error) {
+	var flagVals formPostFlagVal
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	flagSet.StringVar(&flagVals.prefixFlag, "prefix", "", "Prefix added to the names of uploaded objects")
+	flagSet.StringVar(&flagVals.redirectFlag, "redirect", "", "URL browsers are sent to after uploading")
+	flagSet.Int64Var(&flagVals.maxFileSizeFlag, "max-file-size", defaultMaxFileSize, "Largest file, in bytes, that may be uploaded")
+	flagSet.IntVar(&flagVals.maxFileCountFlag, "max-file-count", 1, "Most files that may be uploaded at once")
+	lifetime := flagSet.String("lifetime", "1h", "How long the form is valid, such as 1h or 7d")
+	flagSet.StringVar(&flagVals.digestFlag, "digest", "sha256", "Digest used to sign the form: sha1 or sha256")
+	flagSet.StringVar(&flagVals.signKeyFlag, "sign-key", "", "Sign with this key instead of the account's or container's")
+	flagSet.StringVar(&flagVals.htmlFlag, "html", "", "File to write a ready-to-use HTML form to")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	flagVals.lifetimeFlag, err = expiry.ParseDuration(*lifetime)
+	if err != nil || flagVals.lifetimeFlag <= 0 {
+		return nil, fmt.Errorf("Invalid lifetime '%s' (must be a positive duration such as 1h or 7d)", *lifetime)
+	}
+
+	if flagVals.maxFileSizeFlag < 1 {
+		return nil, fmt.Errorf("Max file size must be at least 1 byte")
+	}
+	if flagVals.maxFileCountFlag < 1 {
+		return nil, fmt.Errorf("Max file count must be at least 1")
+	}
+	if _, found := digests[flagVals.digestFlag]; !found {
+		return nil, fmt.Errorf("Digest must be sha1 or sha256")
+	}
+
+	return &flagVals, nil
+}
+
+// formPostBody returns the signature body of a form, which covers the form's path
+// and the values of its other fields, in order.
+func formPostBody(formPath string, fields []formField) string {
+	body := formPath
+	for _, field := range fields {
+		body += "\n" + field.Value
+	}
+
+	return body
+}
+
+// writeForm renders the HTML form to a file.
+func writeForm(htmlPath string, f form) error {
+	file, err := os.Create(htmlPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %s", htmlPath, err)
+	}
+
+	err = formTemplate.Execute(file, f)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write form to %s: %s", htmlPath, err)
+	}
+
+	return nil
+}
+
+// MakeFormPost signs the fields of an HTML form that lets browsers upload files
+// directly into a container, optionally beneath a prefix, until it expires.
+func MakeFormPost(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Signing form")
+
+	container := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	flagVals, err := parseFormPostFlags(args[4:])
+	if err != nil {
+		return "", err
+	}
+
+	key := flagVals.signKeyFlag
+	if key == "" {
+		key, err = signingKey(connection, container)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	storageUrl, err := url.Parse(connection.StorageUrl)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse storage url: %s", err)
+	}
+
+	formPath := storageUrl.Path + "/" + container + "/" + flagVals.prefixFlag
+	expires := time.Now().Add(flagVals.lifetimeFlag)
+
+	fields := []formField{
+		{"redirect", flagVals.redirectFlag},
+		{"max_file_size", strconv.FormatInt(flagVals.maxFileSizeFlag, 10)},
+		{"max_file_count", strconv.Itoa(flagVals.maxFileCountFlag)},
+		{"expires", strconv.FormatInt(expires.Unix(), 10)},
+	}
+
+	fields = append(fields, formField{"signature", sign(key, flagVals.digestFlag, formPostBody(formPath, fields))})
+
+	actionUrl := *storageUrl
+	actionUrl.Path = formPath
+	actionUrl.RawPath = ""
+
+	result := fmt.Sprintf("\r%s%s\n\nForm for uploads to container %s beginning with '%s', valid until %s:\n",
+		w.ClearLine, w.Green("OK"), container, flagVals.prefixFlag, expires.Format(time.RFC3339))
+	result += fmt.Sprintf("\t%s%s\n", w.White("action: "), actionUrl.String())
+	for _, field := range fields {
+		result += fmt.Sprintf("\t%s%s\n", w.White(field.Name+": "), field.Value)
+	}
+
+	if flagVals.htmlFlag != "" {
+		err = writeForm(flagVals.htmlFlag, form{
+			Container:    container,
+			Action:       actionUrl.String(),
+			Fields:       fields,
+			MaxFileCount: flagVals.maxFileCountFlag,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		result += fmt.Sprintf("Wrote HTML form to %s\n", flagVals.htmlFlag)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package tempurl
+
+import "testing"
+
+func TestFormPostBody(t *testing.T) {
+	fields := []formField{
+		{"redirect", "https://srv.com/some-page"},
+		{"max_file_size", "104857600"},
+		{"max_file_count", "10"},
+		{"expires", "1512508563"},
+	}
+	want := "/v1/AUTH_account/container/object_prefix\nhttps://srv.com/some-page\n104857600\n10\n1512508563"
+
+	if got := formPostBody("/v1/AUTH_account/container/object_prefix", fields); got != want {
+		t.Errorf("formPostBody() = %q, want %q", got, want)
+	}
+
+	// Without a redirect, its place in the body is left empty
+	fields[0].Value = ""
+	want = "/v1/AUTH_account/container/\n\n104857600\n10\n1512508563"
+
+	if got := formPostBody("/v1/AUTH_account/container/", fields); got != want {
+		t.Errorf("formPostBody() = %q, want %q", got, want)
+	}
+}
+
+func TestSignFormPost(t *testing.T) {
+	// The fields of the example in the Swift formpost middleware's documentation
+	body := "/v1/AUTH_account/container/object_prefix\nhttps://srv.com/some-page\n104857600\n10\n1512508563"
+
+	tests := []struct {
+		digest string
+		want   string
+	}{
+		{"sha1", "f464b88871a864de289ae4810603f2cf2f169d25"},
+		{"sha256", "a6328d4b42f17ba9dc007361ffa10d301fd7cd1c4f5ee2efeef1acca9c0e55af"},
+	}
+
+	for _, test := range tests {
+		if got := sign("mykey", test.digest, body); got != test.want {
+			t.Errorf("sign(%s, %q) = %s, want %s", test.digest, body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package tempurl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibmjstart/cf-object-storage/expiry"
+	w "github.com/ibmjstart/cf-object-storage/writer"
+	"github.com/ibmjstart/swiftlygo/auth"
+	"github.com/ncw/swift"
+)
+
+// keySize is the number of random bytes in a generated temp URL key.
+const keySize = 32
+
+// digests maps the names accepted by the digest flag to their hash functions.
+var digests = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// methods are the request methods a temp URL can be signed for.
+var methods = map[string]bool{
+	"GET":  true,
+	"PUT":  true,
+	"HEAD": true,
+}
+
+// keyHeaders returns the names of the headers holding the temp URL keys of the
+// account, or of a container if one is given.
+func keyHeaders(container string) (string, string) {
+	scope := "Account"
+	if container != "" {
+		scope = "Container"
+	}
+
+	return fmt.Sprintf("X-%s-Meta-Temp-Url-Key", scope), fmt.Sprintf("X-%s-Meta-Temp-Url-Key-2", scope)
+}
+
+// describeScope names the holder of a set of keys for display.
+func describeScope(container string) string {
+	if container == "" {
+		return "the account"
+	}
+
+	return "container " + container
+}
+
+// getKeys returns the temp URL keys of the account, or of a container if one is given.
+func getKeys(connection *swift.Connection, container string) (string, string, error) {
+	var (
+		headers swift.Headers
+		err     error
+	)
+
+	if container == "" {
+		_, headers, err = connection.Account()
+	} else {
+		_, headers, err = connection.Container(container)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to get temp URL keys of %s: %s", describeScope(container), err)
+	}
+
+	keyHeader, key2Header := keyHeaders(container)
+
+	return headers[keyHeader], headers[key2Header], nil
+}
+
+// setKeys saves temp URL key headers on the account, or on a container if one is given.
+func setKeys(connection *swift.Connection, container string, headers swift.Headers) error {
+	var err error
+	if container == "" {
+		err = connection.AccountUpdate(headers)
+	} else {
+		err = connection.ContainerUpdate(container, headers)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to set temp URL keys of %s: %s", describeScope(container), err)
+	}
+
+	return nil
+}
+
+// generateKey returns a new random temp URL key.
+func generateKey() (string, error) {
+	key := make([]byte, keySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate key: %s", err)
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+// signingKey returns the key used to sign URLs for objects in a container: the
+// account's key if it has one, or else the container's.
+func signingKey(connection *swift.Connection, container string) (string, error) {
+	key, _, err := getKeys(connection, "")
+	if err != nil || key != "" {
+		return key, err
+	}
+
+	key, _, err = getKeys(connection, container)
+	if err != nil || key != "" {
+		return key, err
+	}
+
+	return "", fmt.Errorf("Neither the account nor container %s has a temp URL key (set one with `cf os temp-url-key service_name set`)", container)
+}
+
+// sign returns the hex-encoded HMAC of a signature body using the given digest.
+func sign(key, digest, body string) string {
+	mac := hmac.New(digests[digest], []byte(key))
+	mac.Write([]byte(body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tempUrlBody returns the signature body of a temp URL for an object path, or for
+// every object beginning with a prefix, which is signed with its own marker.
+func tempUrlBody(method, expires, objectPath string, isPrefix bool) string {
+	if isPrefix {
+		objectPath = "prefix:" + objectPath
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s", method, expires, objectPath)
+}
+
+// ManageTempUrlKeys shows, sets, or rotates the temp URL keys of the account or a container.
+func ManageTempUrlKeys(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Fetching temp URL keys")
+
+	action := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	flagArgs := args[4:]
+	newKey := ""
+	if action == "set" && len(flagArgs) > 0 && !strings.HasPrefix(flagArgs[0], "-") {
+		newKey = flagArgs[0]
+		flagArgs = flagArgs[1:]
+	}
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+	container := flagSet.String("container", "", "Manage the keys of this container instead of the account's")
+	second := flagSet.Bool("2", false, "Set the second key rather than the first")
+
+	err := flagSet.Parse(flagArgs)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	keyHeader, key2Header := keyHeaders(*container)
+	scope := describeScope(*container)
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+
+	switch action {
+	case "show":
+		key, key2, err := getKeys(connection, *container)
+		if err != nil {
+			return "", err
+		}
+
+		result += fmt.Sprintf("Temp URL keys of %s:\n", scope)
+		for _, k := range []struct{ label, value string }{{"key: ", key}, {"key-2: ", key2}} {
+			if k.value == "" {
+				k.value = "(not set)"
+			}
+			result += fmt.Sprintf("\t%s%s\n", w.White(k.label), k.value)
+		}
+	case "set":
+		if newKey == "" {
+			newKey, err = generateKey()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		header, label := keyHeader, "key"
+		if *second {
+			header, label = key2Header, "key-2"
+		}
+
+		writer.SetCurrentStage("Setting temp URL key")
+		err = setKeys(connection, *container, swift.Headers{header: newKey})
+		if err != nil {
+			return "", err
+		}
+
+		result += fmt.Sprintf("Set temp URL %s of %s:\n\t%s\n", label, scope, newKey)
+	case "rotate":
+		// The current key moves to key-2, so URLs signed with it remain valid until they expire
+		key, _, err := getKeys(connection, *container)
+		if err != nil {
+			return "", err
+		}
+
+		newKey, err = generateKey()
+		if err != nil {
+			return "", err
+		}
+
+		headers := swift.Headers{keyHeader: newKey}
+		if key != "" {
+			headers[key2Header] = key
+		}
+
+		writer.SetCurrentStage("Rotating temp URL key")
+		err = setKeys(connection, *container, headers)
+		if err != nil {
+			return "", err
+		}
+
+		result += fmt.Sprintf("Rotated temp URL key of %s, moving the previous key to key-2:\n\t%s\n", scope, newKey)
+	default:
+		return "", fmt.Errorf("%s is not a valid action (must be show, set, or rotate)", action)
+	}
+
+	return result, nil
+}
+
+// tempUrlFlagVal holds the flag values of the temp-url subcommand.
+type tempUrlFlagVal struct {
+	prefixFlag   string
+	methodFlag   string
+	lifetimeFlag time.Duration
+	digestFlag   string
+	inlineFlag   bool
+	filenameFlag string
+	signKeyFlag  string
+}
+
+// parseTempUrlFlags reads the flags provided to the temp-url subcommand.
+func parseTempUrlFlags(args []string) (*tempUrlFlagVal, error) {
+	var flagVals tempUrlFlagVal
+
+	flagSet := flag.NewFlagSet("flagSet", flag.ContinueOnError)
+
+	flagSet.StringVar(&flagVals.prefixFlag, "prefix", "", "Sign the URL for every object beginning with this prefix")
+	flagSet.StringVar(&flagVals.methodFlag, "method", "GET", "Request method the URL allows: GET, PUT, or HEAD")
+	lifetime := flagSet.String("lifetime", "1h", "How long the URL is valid, such as 1h or 7d")
+	flagSet.StringVar(&flagVals.digestFlag, "digest", "sha256", "Digest used to sign the URL: sha1 or sha256")
+	flagSet.BoolVar(&flagVals.inlineFlag, "inline", false, "Have browsers display the object rather than download it")
+	flagSet.StringVar(&flagVals.filenameFlag, "filename", "", "File name browsers save the object as")
+	flagSet.StringVar(&flagVals.signKeyFlag, "sign-key", "", "Sign with this key instead of the account's or container's")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse flags: %s", err)
+	}
+
+	flagVals.lifetimeFlag, err = expiry.ParseDuration(*lifetime)
+	if err != nil || flagVals.lifetimeFlag <= 0 {
+		return nil, fmt.Errorf("Invalid lifetime '%s' (must be a positive duration such as 1h or 7d)", *lifetime)
+	}
+
+	flagVals.methodFlag = strings.ToUpper(flagVals.methodFlag)
+	if !methods[flagVals.methodFlag] {
+		return nil, fmt.Errorf("Method must be GET, PUT, or HEAD")
+	}
+	if _, found := digests[flagVals.digestFlag]; !found {
+		return nil, fmt.Errorf("Digest must be sha1 or sha256")
+	}
+	if flagVals.methodFlag == "PUT" && (flagVals.inlineFlag || flagVals.filenameFlag != "") {
+		return nil, fmt.Errorf("-inline and -filename only apply to GET and HEAD URLs")
+	}
+
+	return &flagVals, nil
+}
+
+// MakeTempUrl signs a URL that allows anyone holding it to access an object, or
+// any object beginning with a prefix, until it expires.
+func MakeTempUrl(dest auth.Destination, writer *w.ConsoleWriter, args []string) (string, error) {
+	writer.SetCurrentStage("Signing temp URL")
+
+	container := args[3]
+	connection := dest.(*auth.SwiftDestination).SwiftConnection
+
+	objectName := ""
+	flagArgs := args[4:]
+	if len(flagArgs) > 0 && !strings.HasPrefix(flagArgs[0], "-") {
+		objectName = flagArgs[0]
+		flagArgs = flagArgs[1:]
+	}
+
+	flagVals, err := parseTempUrlFlags(flagArgs)
+	if err != nil {
+		return "", err
+	}
+
+	isPrefix := objectName == ""
+	switch {
+	case !isPrefix && flagVals.prefixFlag != "":
+		return "", fmt.Errorf("Please provide either an object_name or -prefix, not both")
+	case isPrefix:
+		// An empty prefix signs a URL for every object in the container
+		objectName = flagVals.prefixFlag
+	}
+
+	key := flagVals.signKeyFlag
+	if key == "" {
+		key, err = signingKey(connection, container)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	storageUrl, err := url.Parse(connection.StorageUrl)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse storage url: %s", err)
+	}
+
+	// Signatures cover the unescaped path of the object, or of the prefix
+	objectPath := storageUrl.Path + "/" + container + "/" + objectName
+	expires := time.Now().Add(flagVals.lifetimeFlag)
+	expiresVal := strconv.FormatInt(expires.Unix(), 10)
+
+	signature := sign(key, flagVals.digestFlag, tempUrlBody(flagVals.methodFlag, expiresVal, objectPath, isPrefix))
+
+	query := url.Values{
+		"temp_url_sig":     []string{signature},
+		"temp_url_expires": []string{expiresVal},
+	}
+	if isPrefix {
+		query.Set("temp_url_prefix", objectName)
+	}
+	if flagVals.inlineFlag {
+		query.Set("inline", "")
+	}
+	if flagVals.filenameFlag != "" {
+		query.Set("filename", flagVals.filenameFlag)
+	}
+
+	signedUrl := *storageUrl
+	signedUrl.Path = objectPath
+	signedUrl.RawPath = ""
+	signedUrl.RawQuery = query.Encode()
+
+	result := fmt.Sprintf("\r%s%s\n\n", w.ClearLine, w.Green("OK"))
+	if isPrefix {
+		result += fmt.Sprintf("Temp URL for %s of objects in container %s beginning with '%s', valid until %s:\n%s\n",
+			flagVals.methodFlag, container, objectName, expires.Format(time.RFC3339), signedUrl.String())
+		result += "Append an object's name, less the prefix, to the URL's path to access it\n"
+	} else {
+		result += fmt.Sprintf("Temp URL for %s of object %s, valid until %s:\n%s\n",
+			flagVals.methodFlag, objectName, expires.Format(time.RFC3339), signedUrl.String())
+	}
+
+	return result, nil
+}
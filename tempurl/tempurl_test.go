@@ -0,0 +1,46 @@
+package tempurl
+
+import "testing"
+
+func TestTempUrlBody(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		isPrefix bool
+		want     string
+	}{
+		{"GET", "/v1/AUTH_account/container/object", false, "GET\n1512508563\n/v1/AUTH_account/container/object"},
+		{"GET", "/v1/AUTH_account/container/pre", true, "GET\n1512508563\nprefix:/v1/AUTH_account/container/pre"},
+		{"PUT", "/v1/AUTH_account/container/", true, "PUT\n1512508563\nprefix:/v1/AUTH_account/container/"},
+	}
+
+	for _, test := range tests {
+		if got := tempUrlBody(test.method, "1512508563", test.path, test.isPrefix); got != test.want {
+			t.Errorf("tempUrlBody(%q, %q, %t) = %q, want %q", test.method, test.path, test.isPrefix, got, test.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		digest string
+		body   string
+		want   string
+	}{
+		// The example in the Swift tempurl middleware's documentation
+		{"sha256", "GET\n1512508563\n/v1/AUTH_account/container/object",
+			"732fcac368abb10c78a4cbe95c3fab7f311584532bf779abd5074e13cbe8b88b"},
+		{"sha1", "GET\n1512508563\n/v1/AUTH_account/container/object",
+			"a83dcf0587a84542b5f23a7807c38ff4bcaa6924"},
+		{"sha1", "PUT\n1512508563\n/v1/AUTH_account/container/object",
+			"e86547abed7b8af1f018c390a25d0ff95edbaa09"},
+		{"sha256", "GET\n1512508563\nprefix:/v1/AUTH_account/container/pre",
+			"32f398a48a1a8ca6f2711efcca444100723360239733c6e7b31d868f62f66b47"},
+	}
+
+	for _, test := range tests {
+		if got := sign("mykey", test.digest, test.body); got != test.want {
+			t.Errorf("sign(%s, %q) = %s, want %s", test.digest, test.body, got, test.want)
+		}
+	}
+}
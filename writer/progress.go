@@ -0,0 +1,85 @@
+package writer
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Progress reports how far a transfer has progressed, for display in a progress bar.
+type Progress interface {
+	PercentComplete() float64
+	RateMBPS() float64
+}
+
+// TransferProgress counts the bytes transferred, through any number of readers,
+// out of an expected total.
+type TransferProgress struct {
+	total     int64
+	done      int64
+	startTime time.Time
+}
+
+// progressReader counts the bytes read through it towards a TransferProgress.
+type progressReader struct {
+	reader   io.Reader
+	progress *TransferProgress
+}
+
+// NewTransferProgress creates a TransferProgress expecting to transfer total bytes.
+func NewTransferProgress(total int64) *TransferProgress {
+	return &TransferProgress{
+		total:     total,
+		startTime: time.Now(),
+	}
+}
+
+// Reader wraps a reader so that the bytes read from it count towards the progress.
+func (p *TransferProgress) Reader(reader io.Reader) io.Reader {
+	return &progressReader{
+		reader:   reader,
+		progress: p,
+	}
+}
+
+// Read reads from the underlying reader, counting the bytes read.
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	atomic.AddInt64(&r.progress.done, int64(n))
+
+	return n, err
+}
+
+// Add counts bytes transferred without being read through a Reader, such as those of skipped files.
+func (p *TransferProgress) Add(n int64) {
+	atomic.AddInt64(&p.done, n)
+}
+
+// BytesDone returns the number of bytes transferred so far.
+func (p *TransferProgress) BytesDone() int64 {
+	return atomic.LoadInt64(&p.done)
+}
+
+// PercentComplete returns the percentage of the expected bytes that have been transferred.
+func (p *TransferProgress) PercentComplete() float64 {
+	if p.total <= 0 {
+		return 100
+	}
+
+	percent := float64(p.BytesDone()) / float64(p.total) * 100
+	if percent > 100 {
+		percent = 100
+	}
+
+	return percent
+}
+
+// RateMBPS returns the average transfer rate in megabytes per second.
+func (p *TransferProgress) RateMBPS() float64 {
+	elapsed := time.Since(p.startTime).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(p.BytesDone()) / 1000 / 1000 / elapsed
+}
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"fmt"
+	"io"
 	"runtime"
 	"time"
 
@@ -30,11 +31,28 @@ var Green (func(string, ...interface{}) string) = color.New(color.FgGreen, color
 // Red formats a string to display in red.
 var Red (func(string, ...interface{}) string) = color.New(color.FgRed, color.Bold).SprintfFunc()
 
+// FormatBytes formats a size in bytes for display, such as 1.5 KiB or 2.0 GiB.
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // ConsoleWriter asynchronously prints the current state to the console.
 type ConsoleWriter struct {
 	quit         chan int
 	currentStage chan string
-	status       *sg.Status
+	status       Progress
+	output       io.Writer
 	Write        func()
 }
 
@@ -44,6 +62,7 @@ func NewConsoleWriter() *ConsoleWriter {
 		quit:         make(chan int),
 		currentStage: make(chan string),
 		status:       nil,
+		output:       color.Output,
 	}
 
 	// Disable color and escape sequences on unsupported systems
@@ -60,7 +79,13 @@ func NewConsoleWriter() *ConsoleWriter {
 
 // Print prints using the color package's colored output writer
 func (c *ConsoleWriter) Print(format string, args ...interface{}) {
-	fmt.Fprintf(color.Output, format, args...)
+	fmt.Fprintf(c.output, format, args...)
+}
+
+// UseStderr sends all of the writer's output to stderr, leaving stdout free
+// for data such as a downloaded object. It must be called before Write.
+func (c *ConsoleWriter) UseStderr() {
+	c.output = color.Error
 }
 
 // Quit sends a kill signal to this ConsoleWriter.
@@ -76,7 +101,7 @@ func (c *ConsoleWriter) SetCurrentStage(currentStage string) {
 }
 
 // SetStatus gives the writer the uploader's status, if available.
-func (c *ConsoleWriter) SetStatus(status *sg.Status) {
+func (c *ConsoleWriter) SetStatus(status Progress) {
 	c.status = status
 }
 
@@ -101,7 +126,7 @@ func (c *ConsoleWriter) writeWithANSI() {
 			first = false
 		}
 
-		fmt.Print(out)
+		fmt.Fprint(c.output, out)
 		count = (count + 1) % len(loading)
 	}
 
@@ -109,7 +134,7 @@ func (c *ConsoleWriter) writeWithANSI() {
 		select {
 		case <-c.quit:
 			if c.status != nil {
-				fmt.Printf("\r%s", upLine)
+				fmt.Fprintf(c.output, "\r%s", upLine)
 			}
 			return
 		case cur = <-c.currentStage:
@@ -128,19 +153,19 @@ func (c *ConsoleWriter) writeWithoutANSI() {
 		case <-c.quit:
 			return
 		case stage := <-c.currentStage:
-			fmt.Println(stage)
+			fmt.Fprintln(c.output, stage)
 		}
 	}
 }
 
-// getStats prints a progress bar for the SLO upload.
-func getStats(status *sg.Status, out string, first bool) string {
+// getStats prints a progress bar for the upload.
+func getStats(status Progress, out string, first bool) string {
 	progress := [11]string{">         ", "=>        ", "==>       ", "===>      ", "====>     ",
 		"=====>    ", "======>   ", "=======>  ", "========> ", "=========>", "=========="}
 
 	percent := status.PercentComplete()
 	percentStr := fmt.Sprintf("%.0f%%", percent)
-	if percent == 100 {
+	if _, isSlo := status.(*sg.Status); isSlo && percent == 100 {
 		percentStr += " Uploading manifest"
 	}
 